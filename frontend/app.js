@@ -147,6 +147,11 @@ document.addEventListener("DOMContentLoaded", () => {
       const leaderboardResponse = await response.json();
       const entries = leaderboardResponse.data?.entries || [];
       updateLeaderboard(entries);
+      if (leaderboardResponse.data?.frozen) {
+        const li = document.createElement("li");
+        li.textContent = "❄️ Scoreboard frozen, results are revealed after the room ends.";
+        leaderboardList.prepend(li);
+      }
     } catch (error) {
       console.error("Failed to fetch leaderboard:", error);
       updateLeaderboard([]); // Clear leaderboard on error
@@ -172,15 +177,44 @@ document.addEventListener("DOMContentLoaded", () => {
       `${apiBaseUrl}/events?room_id=${roomId}&player_id=${currentPlayer.id}`,
     );
 
-    const handleLeaderboardUpdate = (event) => {
-      console.log(
-        `Leaderboard-updating event received: ${event.type}. Refreshing leaderboard.`,
-      );
+    // The standings come with LEADERBOARD_UPDATED, other events only get logged
+    const logRoomEvent = (event) => {
+      console.log(`Room event received: ${event.type}.`);
       console.log("Event data:", event.data);
-      fetchLeaderboard(roomId);
     };
 
-    // These events indicate that the leaderboard state has changed.
+    const showLeaderboard = (leaderboard) => {
+      updateLeaderboard(leaderboard.entries);
+      if (leaderboard.frozen) {
+        const li = document.createElement("li");
+        li.textContent = "❄️ Scoreboard frozen, results are revealed after the room ends.";
+        leaderboardList.prepend(li);
+      }
+    };
+
+    // The first event of the connection, the state of the room to start from
+    leaderboardEventSource.addEventListener("ROOM_SNAPSHOT", (event) => {
+      try {
+        const { data } = JSON.parse(event.data);
+        showLeaderboard(data.leaderboard);
+        submitButton.disabled = data.room.status !== "running";
+      } catch (e) {
+        console.error("Failed to parse room snapshot:", e);
+        fetchLeaderboard(roomId);
+      }
+    });
+
+    leaderboardEventSource.addEventListener("LEADERBOARD_UPDATED", (event) => {
+      try {
+        const { data } = JSON.parse(event.data);
+        showLeaderboard(data);
+      } catch (e) {
+        console.error("Failed to parse leaderboard event data:", e);
+        fetchLeaderboard(roomId);
+      }
+    });
+
+    // The verdict of the player's own submission
     leaderboardEventSource.addEventListener(
       "CORRECT_SOLUTION_SUBMITTED",
       (event) => {
@@ -211,16 +245,52 @@ document.addEventListener("DOMContentLoaded", () => {
         submitButton.disabled = false;
         submitButton.textContent = "Submit Solution";
 
-        handleLeaderboardUpdate(event);
+        logRoomEvent(event);
       },
     );
+    leaderboardEventSource.addEventListener(
+      "PARTIAL_SOLUTION_SUBMITTED",
+      logRoomEvent,
+    );
+    // Match lifecycle, submissions are only accepted while the room is running
+    leaderboardEventSource.addEventListener("ROOM_COUNTDOWN", (event) => {
+      console.log("Room countdown event received:", event.data);
+    });
+    leaderboardEventSource.addEventListener("ROOM_STARTED", (event) => {
+      console.log("Room started event received:", event.data);
+      submitButton.disabled = false;
+      logRoomEvent(event);
+    });
+    leaderboardEventSource.addEventListener("ROOM_FINISHED", (event) => {
+      console.log("Room finished event received:", event.data);
+      submitButton.disabled = true;
+      alert("The match is over, no more submissions are accepted.");
+      logRoomEvent(event);
+    });
+    leaderboardEventSource.addEventListener("ROOM_SETTINGS_UPDATED", (event) => {
+      console.log("Room settings updated event received:", event.data);
+    });
+
+    // The scoreboard freeze and its reveal change what players get to see
+    leaderboardEventSource.addEventListener(
+      "SCOREBOARD_FROZEN",
+      logRoomEvent,
+    );
+    leaderboardEventSource.addEventListener(
+      "RESULT_REVEALED",
+      logRoomEvent,
+    );
+    leaderboardEventSource.addEventListener(
+      "SCOREBOARD_UNFROZEN",
+      logRoomEvent,
+    );
     leaderboardEventSource.addEventListener(
       "PLAYER_JOINED",
-      handleLeaderboardUpdate,
+      logRoomEvent,
     );
     leaderboardEventSource.addEventListener(
       "PLAYER_LEFT",
-      handleLeaderboardUpdate,
+      logRoomEvent,
     );
 
     // Handle wrong submissions specifically to show logs
@@ -231,13 +301,11 @@ document.addEventListener("DOMContentLoaded", () => {
         try {
           const eventPayload = JSON.parse(event.data);
 
-          // The backend sends the log in the format "log:THE_ACTUAL_LOG"
-          // in the `Data` field of the event payload.
+          // The verdict carries the judge's feedback in `data.message`,
+          // see GET /events/schema for the payload of every event.
           let logMessage = "An unknown error occurred.";
-          if (eventPayload && typeof eventPayload.Data === "string") {
-            logMessage = eventPayload.Data.startsWith("log:")
-              ? eventPayload.Data.substring(4)
-              : eventPayload.Data;
+          if (eventPayload && eventPayload.data) {
+            logMessage = eventPayload.data.message || eventPayload.data.status;
           }
 
           // Format Docker execution errors better
@@ -272,11 +340,59 @@ document.addEventListener("DOMContentLoaded", () => {
       },
     );
 
+    // A resubmitted solution of an already solved question, no points change
+    leaderboardEventSource.addEventListener(
+      "QUESTION_ALREADY_SOLVED",
+      (event) => {
+        console.log("Question already solved event received:", event.data);
+        if (executionStatus) {
+          if (executionStatus.timeoutId) {
+            clearTimeout(executionStatus.timeoutId);
+          }
+          executionStatus.innerHTML = `
+            <div style="color: #1976d2; font-weight: bold; margin-top: 10px; padding: 10px; background-color: #e3f2fd; border-radius: 4px;">
+              ℹ️ Solution accepted, but you already solved this question. No points awarded.
+            </div>
+          `;
+          setTimeout(() => {
+            if (executionStatus) {
+              executionStatus.remove();
+              executionStatus = null;
+            }
+          }, 3000);
+        }
+        isExecuting = false;
+        submitButton.disabled = false;
+        submitButton.textContent = "Submit Solution";
+      },
+    );
+
+    // A host removed a player from the room
+    leaderboardEventSource.addEventListener("PLAYER_KICKED", (event) => {
+      console.log("Player kicked event received:", event.data);
+      try {
+        const eventPayload = JSON.parse(event.data);
+        const kickedId = eventPayload.data?.player_id;
+        if (kickedId === currentPlayer.id) {
+          alert("You have been removed from the room by a host.");
+          submitButton.disabled = true;
+          // the server closes the stream and won't let the player back in
+          leaderboardEventSource.close();
+        }
+      } catch (e) {
+        console.error("Failed to parse player kicked event data:", e);
+      }
+      logRoomEvent(event);
+    });
+
     // This event indicates a room was removed, so we need to update the room list.
     leaderboardEventSource.addEventListener("ROOM_DELETED", (event) => {
       console.log("Room deleted event received:", event.data);
       alert("A room has been deleted. The interface will now refresh.");
 
+      // The room is gone, reconnecting would only fail
+      leaderboardEventSource.close();
+
       // Reset the current room selection
       currentRoomId = null;
       roomsDropdown.value = "";
@@ -288,6 +404,16 @@ document.addEventListener("DOMContentLoaded", () => {
       fetchRooms();
     });
 
+    // The server dropped a connection that fell behind, the browser reconnects and catches up
+    leaderboardEventSource.addEventListener("STREAM_CLOSED", (event) => {
+      console.warn("Room event stream closed by the server:", event.data);
+    });
+
+    // An idle room was unloaded by the server, the browser reconnects and the room is loaded again
+    leaderboardEventSource.addEventListener("ROOM_CLOSED", (event) => {
+      console.log("Room closed event received:", event.data);
+    });
+
     leaderboardEventSource.onerror = (error) => {
       console.error(
         "SSE connection error. The browser will attempt to reconnect automatically.",
@@ -448,9 +574,6 @@ document.addEventListener("DOMContentLoaded", () => {
       if (executionStatus) {
         executionStatus.timeoutId = executionTimeout;
       }
-
-      // Refresh leaderboard after submission
-      setTimeout(() => fetchLeaderboard(currentRoomId), 100);
     } catch (error) {
       console.error("Failed to submit solution:", error);
       alert(`Error submitting solution: ${error.message}`);
@@ -532,6 +655,34 @@ document.addEventListener("DOMContentLoaded", () => {
     return templates[language] || "// Write your solution here";
   }
 
+  /**
+   * Joins a room, the backend rejects full rooms and players removed by a host.
+   * @param {string} roomId - The ID of the room to join.
+   * @returns {Promise<boolean>} Whether the player got a seat.
+   */
+  async function joinRoom(roomId) {
+    try {
+      const response = await fetch(`${apiBaseUrl}/rooms/${roomId}/join`, {
+        method: "POST",
+        headers: {
+          "Content-Type": "application/json",
+        },
+        body: JSON.stringify({ player_id: currentPlayer.id }),
+      });
+
+      if (!response.ok) {
+        const errorData = await response.json();
+        throw new Error(errorData.message || "Failed to join room.");
+      }
+
+      return true;
+    } catch (error) {
+      console.error("Failed to join room:", error);
+      alert(`Error joining room: ${error.message}`);
+      return false;
+    }
+  }
+
   /**
    * Handles leaving the current room.
    */
@@ -551,7 +702,7 @@ document.addEventListener("DOMContentLoaded", () => {
 
     try {
       const response = await fetch(
-        `${apiBaseUrl}/rooms/${currentRoomId}/players/${currentPlayer.id}`,
+        `${apiBaseUrl}/rooms/${currentRoomId}/players/${currentPlayer.id}?player_id=${currentPlayer.id}`,
         {
           method: "DELETE",
         },
@@ -594,10 +745,14 @@ document.addEventListener("DOMContentLoaded", () => {
       // Show the leave room button when a room is selected
       leaveRoomButton.style.display = "block";
 
-      // Fetch initial leaderboard data
-      fetchLeaderboard(currentRoomId);
-      // Connect to SSE for real-time updates
-      connectToRoomEvents(currentRoomId);
+      // Take a seat in the room before listening to its events
+      joinRoom(currentRoomId).then((joined) => {
+        if (!joined) {
+          return;
+        }
+        // Connect to SSE for real-time updates, the first event is a snapshot of the room
+        connectToRoomEvents(currentRoomId);
+      });
     } else {
       // Hide the leave room button when no room is selected
       leaveRoomButton.style.display = "none";
@@ -630,7 +785,11 @@ document.addEventListener("DOMContentLoaded", () => {
         headers: {
           "Content-Type": "application/json",
         },
-        body: JSON.stringify({ name, description }),
+        body: JSON.stringify({
+          name,
+          description,
+          player_id: currentPlayer.id,
+        }),
       });
 
       if (!response.ok) {
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"golang-realtime/database"
 	"golang-realtime/internal/channels"
 	"golang-realtime/internal/executor"
@@ -12,6 +13,7 @@ import (
 	"os"
 	"runtime/debug"
 	"sync"
+	"time"
 
 	"github.com/joho/godotenv"
 	"github.com/lmittmann/tint"
@@ -62,7 +64,7 @@ func main() {
 	slog.SetDefault(logger) // Set default for any library using slog's default logger
 
 	worker, err := executor.NewWorkerPool(logger, queries, &executor.WorkerPoolOptions{
-		MaxWorkers:       5,
+		MaxWorkers:       5, // 10 containers, two per worker for interactive jobs
 		MemoryLimitBytes: 256,
 		MaxJobCount:      3,
 		CpuNanoLimit:     5000,
@@ -71,8 +73,25 @@ func main() {
 		panic(err)
 	}
 	gr := channels.NewGlobalRooms(queries, logger, worker)
+	if err := gr.LoadRooms(context.Background()); err != nil {
+		panic(err)
+	}
+	// rooms nobody used for a while leave memory, they are loaded again on their next use
+	idleTimeout := time.Duration(env.GetInt("ROOM_IDLE_MINUTES", int(channels.DefaultRoomIdleTimeout/time.Minute))) * time.Minute
+	go gr.EvictIdleRooms(idleTimeout)
+
+	// admins see live standings during a scoreboard freeze and drive the reveal
+	adminToken := env.GetString("ADMIN_TOKEN", "")
+
+	// every SSE connection gets its own queue, a client that can't keep up is dropped and catches up on reconnect
+	defaults := channels.DefaultStreamOptions()
+	stream := channels.StreamOptions{
+		BufferSize:          env.GetInt("SSE_BUFFER_SIZE", defaults.BufferSize),
+		HeartbeatInterval:   time.Duration(env.GetInt("SSE_HEARTBEAT_SECONDS", int(defaults.HeartbeatInterval/time.Second))) * time.Second,
+		SlowConsumerTimeout: time.Duration(env.GetInt("SSE_SLOW_CONSUMER_SECONDS", int(defaults.SlowConsumerTimeout/time.Second))) * time.Second,
+	}
 
-	handlerRepo := handlers.NewHandlerRepo(logger, gr, queries)
+	handlerRepo := handlers.NewHandlerRepo(logger, gr, queries, adminToken, stream)
 
 	app := &Application{
 		cfg:      cfg,
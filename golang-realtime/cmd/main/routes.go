@@ -14,22 +14,45 @@ func (app *Application) routes() http.Handler {
 
 	mux.Route("/events", func(r chi.Router) {
 		r.Get("/", app.handlers.EventHandler)
+		r.Get("/schema", app.handlers.EventSchemaHandler)
 	})
 
+	// one socket to follow a room and act in it, the same events as /events
+	mux.Get("/ws", app.handlers.WebSocketHandler)
+
 	mux.Route("/submission", func(r chi.Router) {
 		r.Post("/", app.handlers.SubmitSolutionHandler)
 	})
 
+	mux.Route("/run", func(r chi.Router) {
+		r.Post("/", app.handlers.RunCodeHandler)
+	})
+
 	mux.Route("/rooms", func(r chi.Router) {
 		r.Get("/", app.handlers.ListRoomsHandler)
 		r.Post("/", app.handlers.CreateRoomHandler)
+		r.Post("/join", app.handlers.JoinRoomByInviteCodeHandler)
+		r.Patch("/{roomId}", app.handlers.UpdateRoomSettingsHandler)
 		r.Delete("/{roomId}", app.handlers.DeleteRoomHandler)
 
 		r.Get("/{roomId}/leaderboard", app.handlers.GetLeaderboardHandler)
+		r.Get("/{roomId}/scoreboard", app.handlers.GetScoreboardHandler)
+		r.Get("/{roomId}/questions", app.handlers.ListRoomQuestionsHandler)
+		r.Post("/{roomId}/start", app.handlers.StartMatchHandler)
+		r.Post("/{roomId}/finish", app.handlers.FinishMatchHandler)
+		r.Post("/{roomId}/archive", app.handlers.ArchiveRoomHandler)
+		r.Post("/{roomId}/reveal", app.handlers.RevealNextResultHandler)
 
+		r.Post("/{roomId}/join", app.handlers.JoinRoomHandler)
 		r.Delete("/{roomId}/players/{playerId}", app.handlers.LeaveRoomHandler)
+
+		r.Get("/{roomId}/hosts", app.handlers.ListRoomHostsHandler)
+		r.Post("/{roomId}/hosts", app.handlers.AddRoomHostHandler)
+		r.Delete("/{roomId}/hosts/{playerId}", app.handlers.RemoveRoomHostHandler)
 	})
 
+	mux.Get("/debug/vars", app.handlers.MetricsHandler)
+
 	mux.Route("/players", func(r chi.Router) {
 		r.Post("/", app.handlers.CreatePlayerHandler)
 		r.Post("/login", app.handlers.LoginHandler)
@@ -37,6 +60,7 @@ func (app *Application) routes() http.Handler {
 
 	mux.Route("/questions", func(r chi.Router) {
 		r.Get("/", app.handlers.ListQuestionsHandler)
+		r.Get("/{questionId}/samples", app.handlers.ListSampleTestCasesHandler)
 	})
 
 	return mux
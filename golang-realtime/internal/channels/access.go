@@ -0,0 +1,89 @@
+package channels
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"golang-realtime/internal/store"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Visibility controls who can find and join a room
+type Visibility string
+
+const (
+	// VisibilityPublic rooms are listed and anyone can join
+	VisibilityPublic Visibility = "public"
+	// VisibilityUnlisted rooms are not listed, anyone who knows the room can join
+	VisibilityUnlisted Visibility = "unlisted"
+	// VisibilityPrivate rooms are not listed and need the invite code or the password to join
+	VisibilityPrivate Visibility = "private"
+)
+
+const (
+	inviteCodeLength = 8
+	// no 0/O or 1/I/L, invite codes are read out loud
+	inviteCodeAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+)
+
+var (
+	ErrAccessDenied = errors.New("invalid invite code or password")
+	ErrRoomFull     = errors.New("room is full")
+	ErrPlayerKicked = errors.New("player was removed from the room")
+	ErrRoomClosed   = errors.New("room is archived")
+)
+
+// ParseVisibility validates a room visibility, an empty string falls back to VisibilityPublic
+func ParseVisibility(s string) (Visibility, error) {
+	switch visibility := Visibility(s); visibility {
+	case "":
+		return VisibilityPublic, nil
+	case VisibilityPublic, VisibilityUnlisted, VisibilityPrivate:
+		return visibility, nil
+	default:
+		return "", fmt.Errorf("unknown room visibility %q", s)
+	}
+}
+
+// NewInviteCode returns a short random code to share a room with
+func NewInviteCode() (string, error) {
+	b := make([]byte, inviteCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = inviteCodeAlphabet[int(b[i])%len(inviteCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// HashRoomPassword returns what is stored of a room password, only its bcrypt hash
+func HashRoomPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckRoomAccess validates the invite code or password given to join a private room
+func CheckRoomAccess(room store.Room, inviteCode, password string) error {
+	if Visibility(room.Visibility) != VisibilityPrivate {
+		return nil
+	}
+
+	if inviteCode != "" && room.InviteCode.Valid && secretEqual(inviteCode, room.InviteCode.String) {
+		return nil
+	}
+	if password != "" && room.Password.Valid && bcrypt.CompareHashAndPassword([]byte(room.Password.String), []byte(password)) == nil {
+		return nil
+	}
+
+	return ErrAccessDenied
+}
+
+func secretEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
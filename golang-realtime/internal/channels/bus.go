@@ -0,0 +1,131 @@
+package channels
+
+import (
+	"golang-realtime/internal/events"
+)
+
+const (
+	// DefaultSubscriptionBuffer is how many events a subscriber may fall behind before it misses some
+	DefaultSubscriptionBuffer = 64
+)
+
+// eventHandler processes one kind of event on the room's event loop
+type eventHandler func(events.Event) error
+
+// on registers the handler of the events of type E, replacing any previous one
+func on[E events.Event](rm *RoomManager, handle func(E) error) {
+	var zero E
+	rm.handlers[zero.Kind()] = func(e events.Event) error {
+		return handle(e.(E))
+	}
+}
+
+// registerHandlers wires every kind of event the room's event loop knows about
+func (rm *RoomManager) registerHandlers() {
+	on(rm, func(e events.SolutionSubmitted) error {
+		rm.enqueueJudging(e)
+		return nil
+	})
+	on(rm, rm.processSolutionResult)
+	on(rm, func(e events.PlayerJoined) error {
+		err := rm.processPlayerJoined(e)
+		if e.Done != nil {
+			e.Done <- err
+		}
+		return err
+	})
+	on(rm, rm.processPlayerLeft)
+	on(rm, rm.processPlayerKicked)
+	on(rm, rm.processRoomDeleted)
+	on(rm, rm.processCountdownEnded)
+	on(rm, rm.processMatchEnded)
+	on(rm, rm.processChatMessage)
+	on(rm, rm.processSettingsRequested)
+	on(rm, rm.processSnapshotRequested)
+}
+
+// handle runs the handler of the event, then hands the event to the subscribers.
+// If the handler recalculated the leaderboard, the new standings are sent to the room
+// and the subscribers get LeaderboardUpdated
+func (rm *RoomManager) handle(event events.Event) {
+	handler, ok := rm.handlers[event.Kind()]
+	if !ok {
+		rm.logger.Error("no handler for room event", "room_id", rm.RoomId, "kind", event.Kind())
+		return
+	}
+
+	if err := handler(event); err != nil {
+		rm.logger.Error("failed to process room event", "room_id", rm.RoomId, "kind", event.Kind(), "error", err)
+	}
+
+	rm.notify(event)
+
+	if rm.leaderboardChanged {
+		rm.leaderboardChanged = false
+		rm.broadcastLeaderboard()
+		rm.notify(events.LeaderboardUpdated{RoomId: rm.RoomId})
+	}
+}
+
+type subscription struct {
+	id     int
+	events chan events.Event
+}
+
+// Subscribe lets an internal observer (metrics, persistence, webhooks...) follow the events of the room.
+// Events arrive in the order the room processed them, once their handler is done. A subscriber that falls
+// more than buffer events behind misses the next ones rather than holding up the room.
+// The channel is closed by the returned function or when the room stops
+func (rm *RoomManager) Subscribe(buffer int) (<-chan events.Event, func()) {
+	rm.subsMu.Lock()
+	defer rm.subsMu.Unlock()
+
+	sub := subscription{id: rm.nextSubID, events: make(chan events.Event, buffer)}
+	rm.nextSubID++
+
+	if rm.stopped() {
+		close(sub.events)
+		return sub.events, func() {}
+	}
+	rm.subs = append(rm.subs, sub)
+
+	return sub.events, func() { rm.unsubscribe(sub.id) }
+}
+
+func (rm *RoomManager) unsubscribe(id int) {
+	rm.subsMu.Lock()
+	defer rm.subsMu.Unlock()
+
+	for i, sub := range rm.subs {
+		if sub.id == id {
+			close(sub.events)
+			rm.subs = append(rm.subs[:i], rm.subs[i+1:]...)
+			return
+		}
+	}
+}
+
+// notify hands the event to every subscriber without ever blocking
+func (rm *RoomManager) notify(event events.Event) {
+	rm.subsMu.Lock()
+	defer rm.subsMu.Unlock()
+
+	for _, sub := range rm.subs {
+		select {
+		case sub.events <- event:
+		default:
+			rm.logger.Warn("room subscriber is too slow, event dropped", "room_id", rm.RoomId, "kind", event.Kind())
+		}
+	}
+}
+
+// closeSubscriptions ends every subscription once the room stopped
+func (rm *RoomManager) closeSubscriptions() {
+	rm.subsMu.Lock()
+	defer rm.subsMu.Unlock()
+
+	for _, sub := range rm.subs {
+		close(sub.events)
+	}
+	rm.subs = nil
+}
@@ -0,0 +1,180 @@
+package channels
+
+import (
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+// testEvent is a kind of event only the tests publish
+type testEvent struct {
+	n int
+}
+
+func (e testEvent) Kind() events.Kind { return "test" }
+func (e testEvent) Room() int32       { return 1 }
+
+// roomClosed is the final event the tests stop their rooms with
+var roomClosed = events.SseEvent{EventType: events.ROOM_CLOSED}
+
+// next waits for the next event of the subscription
+func next(t *testing.T, sub <-chan events.Event) events.Event {
+	t.Helper()
+
+	select {
+	case e, ok := <-sub:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatal("no event within a second")
+		return nil
+	}
+}
+
+func TestHandlerRunsBeforeSubscribers(t *testing.T) {
+	rm := NewRoomManager(1, store.New(newFakeDB()), nil)
+	sub, unsubscribe := rm.Subscribe(DefaultSubscriptionBuffer)
+	defer unsubscribe()
+
+	var queuedDuringHandler int
+	handled := false
+	on(rm, func(e testEvent) error {
+		queuedDuringHandler = len(sub)
+		handled = true
+		return nil
+	})
+	go rm.Start()
+	defer rm.Stop(roomClosed)
+
+	if err := rm.Publish(testEvent{n: 1}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	next(t, sub)
+	if !handled {
+		t.Error("subscriber got the event before its handler ran")
+	}
+	if queuedDuringHandler != 0 {
+		t.Errorf("%d events reached the subscriber while the handler ran, want 0", queuedDuringHandler)
+	}
+}
+
+func TestLeaderboardUpdatedFollowsItsEvent(t *testing.T) {
+	rm := NewRoomManager(1, store.New(newFakeDB()), nil)
+	sub, unsubscribe := rm.Subscribe(DefaultSubscriptionBuffer)
+	defer unsubscribe()
+
+	on(rm, func(e testEvent) error {
+		rm.leaderboardChanged = e.n%2 == 0
+		return nil
+	})
+	go rm.Start()
+	defer rm.Stop(roomClosed)
+
+	for n := 1; n <= 2; n++ {
+		if err := rm.Publish(testEvent{n: n}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	want := []events.Kind{"test", "test", events.KindLeaderboardUpdated}
+	for i, kind := range want {
+		if got := next(t, sub).Kind(); got != kind {
+			t.Fatalf("event %d is %s, want %s", i, got, kind)
+		}
+	}
+	select {
+	case e := <-sub:
+		t.Errorf("unexpected %s after the leaderboard update", e.Kind())
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestSlowSubscriberDoesNotBlockTheRoom(t *testing.T) {
+	rm := NewRoomManager(1, store.New(newFakeDB()), nil)
+	on(rm, func(e testEvent) error { return nil })
+	go rm.Start()
+	defer rm.Stop(roomClosed)
+
+	slow, unsubscribeSlow := rm.Subscribe(1)
+	defer unsubscribeSlow()
+	fast, unsubscribeFast := rm.Subscribe(DefaultSubscriptionBuffer)
+	defer unsubscribeFast()
+
+	const published = 5
+	for n := 1; n <= published; n++ {
+		if err := rm.Publish(testEvent{n: n}); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+	}
+
+	// the room keeps going for the others while the slow subscriber reads nothing
+	for n := 1; n <= published; n++ {
+		if got := next(t, fast).(testEvent).n; got != n {
+			t.Fatalf("fast subscriber got event %d, want %d", got, n)
+		}
+	}
+
+	if got := next(t, slow).(testEvent).n; got != 1 {
+		t.Errorf("slow subscriber got event %d first, want 1", got)
+	}
+	select {
+	case e := <-slow:
+		t.Errorf("slow subscriber got %v past its buffer, want the rest dropped", e)
+	default:
+	}
+}
+
+func TestStopClosesSubscriptions(t *testing.T) {
+	rm := NewRoomManager(1, store.New(newFakeDB()), nil)
+	go rm.Start()
+
+	sub, unsubscribe := rm.Subscribe(DefaultSubscriptionBuffer)
+	rm.Stop(roomClosed)
+	// unsubscribing after the stop must not close the channel twice
+	unsubscribe()
+
+	select {
+	case _, ok := <-sub:
+		if ok {
+			t.Error("got an event after the stop, want the subscription closed")
+		}
+	case <-time.After(time.Second):
+		t.Error("subscription still open after the stop")
+	}
+
+	late, _ := rm.Subscribe(DefaultSubscriptionBuffer)
+	if _, ok := <-late; ok {
+		t.Error("subscribing to a stopped room gave an open subscription")
+	}
+}
+
+func TestCreatedRoomRunsOneLoop(t *testing.T) {
+	gr := NewGlobalRooms(store.New(newFakeDB()), slog.Default(), nil)
+	rm := gr.CreateRoom(1, gr.queries)
+	defer rm.Stop(roomClosed)
+
+	deadline := time.Now().Add(time.Second)
+	for !rm.started.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("created room did not start its event loop")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// a second loop would take events off the queue alongside the first one
+	returned := make(chan struct{})
+	go func() {
+		rm.Start()
+		close(returned)
+	}()
+	select {
+	case <-returned:
+	case <-time.After(time.Second):
+		t.Error("Start entered a second event loop on a running room")
+	}
+}
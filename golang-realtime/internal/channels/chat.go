@@ -0,0 +1,53 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"golang-realtime/internal/events"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxChatMessageLength is the longest chat message a player may send, in characters
+	MaxChatMessageLength = 500
+)
+
+var (
+	ErrChatMessageInvalid = errors.New("chat message must be between 1 and 500 characters")
+)
+
+// Chat sends the player's message to everyone following the room
+func (rm *RoomManager) Chat(playerID int32, message string) error {
+	message = strings.TrimSpace(message)
+	if message == "" || utf8.RuneCountInString(message) > MaxChatMessageLength {
+		return ErrChatMessageInvalid
+	}
+
+	return rm.Publish(events.ChatMessage{
+		PlayerId: playerID,
+		RoomId:   rm.RoomId,
+		Message:  message,
+		SentAt:   time.Now(),
+	})
+}
+
+func (rm *RoomManager) processChatMessage(event events.ChatMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeoutSecond)
+	defer cancel()
+
+	// a kicked player's connections are closed, but a message may have been sent just before
+	if rm.playerState(ctx, event.PlayerId) == PlayerLeft {
+		return ErrPlayerKicked
+	}
+
+	rm.dispatchEvent(events.NewSseEvent(events.CHAT_MESSAGE, rm.RoomId, events.ChatPayload{
+		PlayerID:   event.PlayerId,
+		PlayerName: rm.playerName(ctx, event.PlayerId),
+		Message:    event.Message,
+		SentAt:     event.SentAt,
+	}))
+
+	return nil
+}
@@ -0,0 +1,99 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"golang-realtime/internal/store"
+	"math/rand/v2"
+	"slices"
+)
+
+var (
+	ErrNoQuestionsToDraw = errors.New("no question matches the room's draw")
+)
+
+// drawQuestions attaches a random question set to a room in the lobby that asks for a draw,
+// with q bound to the transaction that starts the match. Rooms with a question set of their own keep it.
+// It returns how many questions were drawn
+func (rm *RoomManager) drawQuestions(ctx context.Context, q *store.Queries, room store.Room) (int, error) {
+	if RoomStatus(room.Status) != StatusLobby || room.DrawCount == 0 {
+		return 0, nil
+	}
+
+	current, err := q.ListRoomQuestions(ctx, rm.RoomId)
+	if err != nil {
+		return 0, err
+	}
+	if len(current) > 0 {
+		return 0, nil
+	}
+
+	candidates, err := q.ListDrawCandidates(ctx, store.ListDrawCandidatesParams{
+		MinDifficulty: room.DrawMinDifficulty,
+		MaxDifficulty: room.DrawMaxDifficulty,
+		Tags:          room.DrawTags,
+		RoomID:        rm.RoomId,
+	})
+	if err != nil {
+		return 0, err
+	}
+	if len(candidates) == 0 {
+		return 0, ErrNoQuestionsToDraw
+	}
+
+	drawn := balancedDraw(candidates, int(room.DrawCount))
+	for i, question := range drawn {
+		err := q.AddRoomQuestion(ctx, store.AddRoomQuestionParams{
+			RoomID:     rm.RoomId,
+			QuestionID: question.ID,
+			Position:   int32(i + 1),
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	rm.logger.Info("room questions drawn", "room_id", rm.RoomId, "requested", room.DrawCount, "drawn", len(drawn))
+
+	return len(drawn), nil
+}
+
+// balancedDraw picks up to n questions, taking one of each difficulty in turn so that
+// no difficulty is drawn twice before the others had their go. The result is ordered by difficulty
+func balancedDraw(candidates []store.ListDrawCandidatesRow, n int) []store.ListDrawCandidatesRow {
+	byDifficulty := make(map[int32][]store.ListDrawCandidatesRow)
+	for _, c := range candidates {
+		byDifficulty[c.Difficulty] = append(byDifficulty[c.Difficulty], c)
+	}
+
+	difficulties := make([]int32, 0, len(byDifficulty))
+	for d, questions := range byDifficulty {
+		rand.Shuffle(len(questions), func(i, j int) {
+			questions[i], questions[j] = questions[j], questions[i]
+		})
+		difficulties = append(difficulties, d)
+	}
+	// with fewer picks than difficulties, which ones get a question is left to chance
+	rand.Shuffle(len(difficulties), func(i, j int) {
+		difficulties[i], difficulties[j] = difficulties[j], difficulties[i]
+	})
+
+	drawn := make([]store.ListDrawCandidatesRow, 0, min(n, len(candidates)))
+	for len(drawn) < n && len(drawn) < len(candidates) {
+		for _, d := range difficulties {
+			if len(drawn) == n {
+				break
+			}
+			if questions := byDifficulty[d]; len(questions) > 0 {
+				drawn = append(drawn, questions[0])
+				byDifficulty[d] = questions[1:]
+			}
+		}
+	}
+
+	slices.SortStableFunc(drawn, func(a, b store.ListDrawCandidatesRow) int {
+		return int(a.Difficulty - b.Difficulty)
+	})
+
+	return drawn
+}
@@ -0,0 +1,193 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"golang-realtime/internal/store"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type solveKey struct {
+	roomID, playerID, questionID int32
+}
+
+// fakeDB stands in for Postgres. It keeps the solve ledger and the accepted submissions,
+// every other query finds no row. A transaction holds the whole database until it ends,
+// the way the row lock of a conflicting insert holds back the other transaction
+type fakeDB struct {
+	mu          sync.Mutex
+	solves      map[solveKey]store.RoomSolve
+	submissions int32
+	queries     []string // names of the queries run, in order
+
+	txMu sync.Mutex // held by the open transaction
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{solves: make(map[solveKey]store.RoomSolve)}
+}
+
+// ran reports whether the query of that name ran
+func (db *fakeDB) ran(name string) bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	for _, q := range db.queries {
+		if q == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (db *fakeDB) run(sql string, args []any) ([]any, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	name := queryName(sql)
+	db.queries = append(db.queries, name)
+
+	switch name {
+	case "CreateAcceptedSubmission":
+		db.submissions++
+		return []any{db.submissions}, nil
+
+	case "CreateRoomSolve":
+		key := solveKey{args[0].(int32), args[1].(int32), args[2].(int32)}
+		if _, ok := db.solves[key]; ok {
+			return nil, pgx.ErrNoRows
+		}
+		solve := store.RoomSolve{
+			RoomID:        key.roomID,
+			PlayerID:      key.playerID,
+			QuestionID:    key.questionID,
+			SubmissionID:  args[3].(pgtype.Int4),
+			SolvedAt:      args[4].(pgtype.Timestamp),
+			AwardedPoints: args[5].(int32),
+			Penalty:       args[6].(int32),
+		}
+		db.solves[key] = solve
+		return solveRow(solve), nil
+
+	case "GetRoomSolve":
+		solve, ok := db.solves[solveKey{args[0].(int32), args[1].(int32), args[2].(int32)}]
+		if !ok {
+			return nil, pgx.ErrNoRows
+		}
+		return solveRow(solve), nil
+	}
+
+	return nil, pgx.ErrNoRows
+}
+
+func solveRow(s store.RoomSolve) []any {
+	return []any{s.RoomID, s.PlayerID, s.QuestionID, s.SubmissionID, s.SolvedAt, s.AwardedPoints, s.Penalty}
+}
+
+// queryName reads the name sqlc puts on the first line of every query
+func queryName(sql string) string {
+	line, _, _ := strings.Cut(sql, "\n")
+	fields := strings.Fields(line)
+	if len(fields) < 3 {
+		return line
+	}
+	return fields[2]
+}
+
+func (db *fakeDB) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	_, err := db.run(sql, args)
+	if err == pgx.ErrNoRows {
+		err = nil
+	}
+	return pgconn.CommandTag{}, err
+}
+
+func (db *fakeDB) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return nil, fmt.Errorf("fakeDB: unexpected list query %s", queryName(sql))
+}
+
+func (db *fakeDB) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	values, err := db.run(sql, args)
+	return fakeRow{values: values, err: err}
+}
+
+func (db *fakeDB) Begin(ctx context.Context) (pgx.Tx, error) {
+	db.txMu.Lock()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	solves := make(map[solveKey]store.RoomSolve, len(db.solves))
+	for k, v := range db.solves {
+		solves[k] = v
+	}
+	return &fakeTx{db: db, solves: solves, submissions: db.submissions}, nil
+}
+
+// fakeTx runs its queries straight on the database, rolling back restores what it saw at the start
+type fakeTx struct {
+	pgx.Tx
+	db          *fakeDB
+	solves      map[solveKey]store.RoomSolve
+	submissions int32
+	done        bool
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return tx.db.Exec(ctx, sql, args...)
+}
+
+func (tx *fakeTx) Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error) {
+	return tx.db.Query(ctx, sql, args...)
+}
+
+func (tx *fakeTx) QueryRow(ctx context.Context, sql string, args ...any) pgx.Row {
+	return tx.db.QueryRow(ctx, sql, args...)
+}
+
+func (tx *fakeTx) Commit(ctx context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+	tx.db.txMu.Unlock()
+	return nil
+}
+
+func (tx *fakeTx) Rollback(ctx context.Context) error {
+	if tx.done {
+		return pgx.ErrTxClosed
+	}
+	tx.done = true
+
+	tx.db.mu.Lock()
+	tx.db.solves, tx.db.submissions = tx.solves, tx.submissions
+	tx.db.mu.Unlock()
+
+	tx.db.txMu.Unlock()
+	return nil
+}
+
+type fakeRow struct {
+	values []any
+	err    error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	if len(dest) != len(r.values) {
+		return fmt.Errorf("fakeRow: %d values scanned into %d destinations", len(r.values), len(dest))
+	}
+	for i, v := range r.values {
+		reflect.ValueOf(dest[i]).Elem().Set(reflect.ValueOf(v))
+	}
+	return nil
+}
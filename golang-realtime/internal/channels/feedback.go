@@ -0,0 +1,55 @@
+package channels
+
+import (
+	"fmt"
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
+)
+
+// FeedbackLevel controls how much a player learns about a failed submission
+type FeedbackLevel string
+
+const (
+	// FeedbackVerdict only reveals the verdict
+	FeedbackVerdict FeedbackLevel = "verdict"
+	// FeedbackFirstFailing also reveals the number of the first failing test
+	FeedbackFirstFailing FeedbackLevel = "first_failing"
+	// FeedbackSampleDiff reveals input, expected and actual output, for sample tests only
+	FeedbackSampleDiff FeedbackLevel = "sample_diff"
+)
+
+const (
+	VisibilitySample = "sample"
+	VisibilityHidden = "hidden"
+)
+
+// ParseFeedbackLevel validates a feedback level, an empty string falls back to FeedbackVerdict
+func ParseFeedbackLevel(s string) (FeedbackLevel, error) {
+	switch level := FeedbackLevel(s); level {
+	case "":
+		return FeedbackVerdict, nil
+	case FeedbackVerdict, FeedbackFirstFailing, FeedbackSampleDiff:
+		return level, nil
+	default:
+		return "", fmt.Errorf("unknown feedback level %q", s)
+	}
+}
+
+// feedbackMessage builds what the player gets to see about a failed test case.
+// Hidden tests never leak their input or expected output, whatever the level
+func feedbackMessage(level FeedbackLevel, status events.JudgeStatus, testNumber int, tc store.TestCase, actualOutput string) string {
+	switch {
+	case level == FeedbackSampleDiff && tc.Visibility == VisibilitySample:
+		if status == events.WrongAnswer {
+			return fmt.Sprintf("%v on sample test %d\nInput:%v, Expected Output:%v, Actual Output: %v",
+				status, testNumber, tc.Input, tc.ExpectedOutput, actualOutput)
+		}
+		return fmt.Sprintf("%v on sample test %d\n%v", status, testNumber, actualOutput)
+
+	case level == FeedbackFirstFailing || level == FeedbackSampleDiff:
+		return fmt.Sprintf("%v on test %d", status, testNumber)
+
+	default:
+		return string(status)
+	}
+}
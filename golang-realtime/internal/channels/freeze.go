@@ -0,0 +1,375 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrNotFrozen       = errors.New("scoreboard is not frozen")
+	ErrRoomNotEnded    = errors.New("room has not ended yet")
+	ErrNothingToReveal = errors.New("no pending result to reveal")
+)
+
+// FrozenStandings is what players see while the scoreboard is frozen
+type FrozenStandings struct {
+	Leaderboard    []store.GetLeaderboardForRoomRow
+	QuestionScores []store.RoomQuestionScore
+	Solves         []store.RoomSolve
+}
+
+// pendingResult is a result judged during the freeze,
+// along with the rows of the player as they were right after it was scored
+type pendingResult struct {
+	Status        events.JudgeStatus             `json:"status"`
+	PlayerID      int32                          `json:"player_id"`
+	QuestionID    int32                          `json:"question_id"`
+	Standing      store.GetLeaderboardForRoomRow `json:"standing"`
+	QuestionScore *store.RoomQuestionScore       `json:"question_score"`
+	Solve         *store.RoomSolve               `json:"solve"`
+}
+
+type scoreboardFreeze struct {
+	standings FrozenStandings
+	pending   []pendingResult
+}
+
+// savedFreeze is how a freeze is kept in the database
+type savedFreeze struct {
+	Standings FrozenStandings `json:"standings"`
+	Pending   []pendingResult `json:"pending"`
+}
+
+// freezeStartsAt returns when the scoreboard of the room freezes, false if it never does
+func freezeStartsAt(room store.Room) (time.Time, bool) {
+	if !room.EndsAt.Valid || room.FreezeMinutes <= 0 {
+		return time.Time{}, false
+	}
+	return room.EndsAt.Time.Add(-time.Duration(room.FreezeMinutes) * time.Minute), true
+}
+
+// Frozen returns the standings players see, false if the scoreboard is live
+func (rm *RoomManager) Frozen() (FrozenStandings, bool) {
+	rm.freezeMu.RLock()
+	defer rm.freezeMu.RUnlock()
+
+	if rm.freeze == nil {
+		return FrozenStandings{}, false
+	}
+	// the standings keep changing on reveal, callers get their own copy
+	return FrozenStandings{
+		Leaderboard:    slices.Clone(rm.freeze.standings.Leaderboard),
+		QuestionScores: slices.Clone(rm.freeze.standings.QuestionScores),
+		Solves:         slices.Clone(rm.freeze.standings.Solves),
+	}, true
+}
+
+// freezeIfDue snapshots the standings once a result comes in during the final minutes of the room.
+// Standings only change through results, so the snapshot taken then is the one of the freeze start
+func (rm *RoomManager) freezeIfDue(ctx context.Context, submittedAt time.Time) (bool, error) {
+	rm.freezeMu.Lock()
+	defer rm.freezeMu.Unlock()
+
+	if rm.freeze != nil {
+		return true, nil
+	}
+	if rm.freezeLifted {
+		return false, nil
+	}
+
+	room, err := rm.queries.GetRoom(ctx, rm.RoomId)
+	if err != nil {
+		return false, err
+	}
+
+	startsAt, ok := freezeStartsAt(room)
+	if !ok || submittedAt.Before(startsAt) {
+		return false, nil
+	}
+
+	leaderboard, err := rm.queries.GetLeaderboardForRoom(ctx, rm.RoomId)
+	if err != nil {
+		return false, err
+	}
+	questionScores, err := rm.queries.ListRoomQuestionScores(ctx, rm.RoomId)
+	if err != nil {
+		return false, err
+	}
+	solves, err := rm.queries.ListRoomSolves(ctx, rm.RoomId)
+	if err != nil {
+		return false, err
+	}
+
+	rm.freeze = &scoreboardFreeze{
+		standings: FrozenStandings{
+			Leaderboard:    leaderboard,
+			QuestionScores: questionScores,
+			Solves:         solves,
+		},
+	}
+
+	rm.saveFreeze(ctx)
+
+	rm.logger.Info("scoreboard frozen", "room_id", rm.RoomId, "freeze_starts_at", startsAt)
+
+	rm.dispatchEvent(events.NewSseEvent(events.SCOREBOARD_FROZEN, rm.RoomId, events.RoomPayload{RoomID: rm.RoomId}))
+
+	return true, nil
+}
+
+// queuePendingResult keeps a scored result back until it is revealed
+func (rm *RoomManager) queuePendingResult(ctx context.Context, e events.SolutionResult) error {
+	if e.Status == events.JudgementFailed {
+		return nil
+	}
+
+	result := pendingResult{
+		Status:     e.Status,
+		PlayerID:   e.SolutionSubmitted.PlayerId,
+		QuestionID: e.SolutionSubmitted.QuestionId,
+	}
+
+	leaderboard, err := rm.queries.GetLeaderboardForRoom(ctx, rm.RoomId)
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(leaderboard, func(row store.GetLeaderboardForRoomRow) bool {
+		return row.PlayerID == result.PlayerID
+	})
+	if idx < 0 {
+		// the player left the room, there is nothing to reveal
+		return nil
+	}
+	result.Standing = leaderboard[idx]
+
+	questionScore, err := rm.queries.GetRoomQuestionScore(ctx, store.GetRoomQuestionScoreParams{
+		RoomID:     rm.RoomId,
+		PlayerID:   result.PlayerID,
+		QuestionID: result.QuestionID,
+	})
+	switch {
+	case err == nil:
+		result.QuestionScore = &questionScore
+	case !errors.Is(err, pgx.ErrNoRows):
+		return err
+	}
+
+	solve, err := rm.queries.GetRoomSolve(ctx, store.GetRoomSolveParams{
+		RoomID:     rm.RoomId,
+		PlayerID:   result.PlayerID,
+		QuestionID: result.QuestionID,
+	})
+	switch {
+	case err == nil:
+		result.Solve = &solve
+	case !errors.Is(err, pgx.ErrNoRows):
+		return err
+	}
+
+	rm.freezeMu.Lock()
+	defer rm.freezeMu.Unlock()
+
+	if rm.freeze == nil {
+		return nil
+	}
+	rm.freeze.pending = append(rm.freeze.pending, result)
+	rm.saveFreeze(ctx)
+
+	return nil
+}
+
+// RevealNext applies the oldest pending result to the frozen standings and broadcasts it.
+// The scoreboard goes live again once every pending result is revealed
+func (rm *RoomManager) RevealNext(ctx context.Context) (events.RevealedResultPayload, error) {
+	revealed, lifted, err := rm.revealNext(ctx)
+	if err != nil && !lifted {
+		return revealed, err
+	}
+
+	// the events go out once freezeMu is released, broadcasting the leaderboard reads the freeze
+	if err == nil {
+		rm.dispatchEvent(events.NewSseEvent(events.RESULT_REVEALED, rm.RoomId, revealed))
+	}
+	if lifted {
+		rm.dispatchEvent(events.NewSseEvent(events.SCOREBOARD_UNFROZEN, rm.RoomId, events.RoomPayload{RoomID: rm.RoomId}))
+	}
+	rm.broadcastLeaderboard()
+
+	return revealed, err
+}
+
+// revealNext pops the oldest pending result, lifted is set once the freeze is over
+func (rm *RoomManager) revealNext(ctx context.Context) (revealed events.RevealedResultPayload, lifted bool, err error) {
+	rm.freezeMu.Lock()
+	defer rm.freezeMu.Unlock()
+
+	if rm.freeze == nil {
+		return revealed, false, ErrNotFrozen
+	}
+
+	room, err := rm.queries.GetRoom(ctx, rm.RoomId)
+	if err != nil {
+		return revealed, false, err
+	}
+	if status := RoomStatus(room.Status); status != StatusFinished && status != StatusArchived {
+		return revealed, false, ErrRoomNotEnded
+	}
+
+	if len(rm.freeze.pending) == 0 {
+		rm.liftFreeze()
+		rm.saveFreeze(ctx)
+		return revealed, true, ErrNothingToReveal
+	}
+
+	next := rm.freeze.pending[0]
+	rm.freeze.pending = rm.freeze.pending[1:]
+	rm.freeze.standings.apply(next)
+
+	revealed = events.RevealedResultPayload{
+		PlayerID:   next.PlayerID,
+		PlayerName: next.Standing.Name,
+		QuestionID: next.QuestionID,
+		Status:     next.Status,
+		Score:      next.Standing.Score.Int32,
+		Penalty:    next.Standing.Penalty,
+		Remaining:  len(rm.freeze.pending),
+	}
+	for _, row := range rm.freeze.standings.Leaderboard {
+		if row.PlayerID == next.PlayerID {
+			revealed.Place = row.Place.Int32
+		}
+	}
+
+	if len(rm.freeze.pending) == 0 {
+		rm.liftFreeze()
+		lifted = true
+	}
+	rm.saveFreeze(ctx)
+
+	return revealed, lifted, nil
+}
+
+// liftFreeze makes the scoreboard live again, the caller must hold freezeMu and tells the room once it is released
+func (rm *RoomManager) liftFreeze() {
+	rm.freeze = nil
+	rm.freezeLifted = true
+
+	rm.logger.Info("scoreboard unfrozen", "room_id", rm.RoomId)
+}
+
+// saveFreeze keeps the freeze in the database, so it survives a restart or an eviction of the manager.
+// A failure is only logged, the match goes on with the freeze in memory. The caller must hold freezeMu
+func (rm *RoomManager) saveFreeze(ctx context.Context) {
+	params := store.SaveRoomFreezeParams{
+		RoomID: rm.RoomId,
+		State:  []byte("{}"),
+		Lifted: rm.freezeLifted,
+	}
+	if rm.freeze != nil {
+		state, err := json.Marshal(savedFreeze{Standings: rm.freeze.standings, Pending: rm.freeze.pending})
+		if err != nil {
+			rm.logger.Error("failed to encode scoreboard freeze", "room_id", rm.RoomId, "error", err)
+			return
+		}
+		params.State = state
+	}
+
+	if err := rm.queries.SaveRoomFreeze(ctx, params); err != nil {
+		rm.logger.Error("failed to save scoreboard freeze", "room_id", rm.RoomId, "error", err)
+	}
+}
+
+// restoreFreeze brings back the freeze saved by a previous manager of the room, if any
+func (rm *RoomManager) restoreFreeze(ctx context.Context) error {
+	saved, err := rm.queries.GetRoomFreeze(ctx, rm.RoomId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rm.freezeMu.Lock()
+	defer rm.freezeMu.Unlock()
+
+	if saved.Lifted {
+		rm.freezeLifted = true
+		return nil
+	}
+
+	var state savedFreeze
+	if err := json.Unmarshal(saved.State, &state); err != nil {
+		return err
+	}
+	rm.freeze = &scoreboardFreeze{standings: state.Standings, pending: state.Pending}
+
+	return nil
+}
+
+// apply replaces the rows of the player with the ones of the revealed result and ranks the standings again
+func (s *FrozenStandings) apply(result pendingResult) {
+	idx := slices.IndexFunc(s.Leaderboard, func(row store.GetLeaderboardForRoomRow) bool {
+		return row.PlayerID == result.PlayerID
+	})
+	if idx < 0 {
+		s.Leaderboard = append(s.Leaderboard, result.Standing)
+	} else {
+		s.Leaderboard[idx] = result.Standing
+	}
+	rankStandings(s.Leaderboard)
+
+	if result.QuestionScore != nil {
+		idx := slices.IndexFunc(s.QuestionScores, func(qs store.RoomQuestionScore) bool {
+			return qs.PlayerID == result.PlayerID && qs.QuestionID == result.QuestionID
+		})
+		if idx < 0 {
+			s.QuestionScores = append(s.QuestionScores, *result.QuestionScore)
+		} else {
+			s.QuestionScores[idx] = *result.QuestionScore
+		}
+	}
+
+	if result.Solve != nil {
+		s.Solves = append(s.Solves, *result.Solve)
+		slices.SortStableFunc(s.Solves, func(a, b store.RoomSolve) int {
+			return a.SolvedAt.Time.Compare(b.SolvedAt.Time)
+		})
+	}
+}
+
+// rankStandings orders the standings the way UpdateRoomPlayerRanks does: score first, then penalty time
+func rankStandings(rows []store.GetLeaderboardForRoomRow) {
+	better := func(a, b store.GetLeaderboardForRoomRow) bool {
+		if a.Score.Int32 != b.Score.Int32 {
+			return a.Score.Int32 > b.Score.Int32
+		}
+		return a.Penalty < b.Penalty
+	}
+
+	slices.SortStableFunc(rows, func(a, b store.GetLeaderboardForRoomRow) int {
+		switch {
+		case better(a, b):
+			return -1
+		case better(b, a):
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	for i := range rows {
+		if i > 0 && !better(rows[i-1], rows[i]) {
+			rows[i].Place = rows[i-1].Place
+			continue
+		}
+		rows[i].Place.Int32 = int32(i + 1)
+		rows[i].Place.Valid = true
+	}
+}
@@ -0,0 +1,93 @@
+package channels
+
+import (
+	"fmt"
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
+)
+
+// enqueueJudging hands a submission to the player's judge, which runs outside the event loop.
+// Submissions of a player are judged one at a time and in order, different players are judged in parallel
+func (rm *RoomManager) enqueueJudging(e events.SolutionSubmitted) {
+	rm.judgeMu.Lock()
+	defer rm.judgeMu.Unlock()
+
+	queue, judging := rm.judgeQueues[e.PlayerId]
+	rm.judgeQueues[e.PlayerId] = append(queue, e)
+	if !judging {
+		go rm.judgePlayer(e.PlayerId)
+	}
+}
+
+// judgePlayer judges the queued submissions of the player until none is left.
+// Results re-enter the event loop through Publish, so a slow judge never holds the loop up
+func (rm *RoomManager) judgePlayer(playerID int32) {
+	for {
+		rm.judgeMu.Lock()
+		queue := rm.judgeQueues[playerID]
+		if len(queue) == 0 || rm.stopped() {
+			delete(rm.judgeQueues, playerID)
+			rm.judgeMu.Unlock()
+			return
+		}
+		next := queue[0]
+		rm.judgeQueues[playerID] = queue[1:]
+		rm.judgeMu.Unlock()
+
+		if err := rm.processSolutionSubmitted(next); err != nil {
+			rm.logger.Error("failed to process solution submitted event", "error", err)
+		}
+	}
+}
+
+// testGroup is a set of test cases judged together, either a subtask or the whole question
+type testGroup struct {
+	subtask   *store.Subtask // nil for tests that belong to no subtask
+	testCases []store.TestCase
+}
+
+// groupTestCases splits the test cases by subtask.
+// Without subtasks the whole question is one group, otherwise the tests outside of any subtask
+// (usually the samples) come first and are worth no points
+func groupTestCases(subtasks []store.Subtask, testCases []store.TestCase) []testGroup {
+	if len(subtasks) == 0 {
+		return []testGroup{{testCases: testCases}}
+	}
+
+	bySubtask := make(map[int32][]store.TestCase, len(subtasks))
+	var ungrouped []store.TestCase
+	for _, tc := range testCases {
+		if !tc.SubtaskID.Valid {
+			ungrouped = append(ungrouped, tc)
+			continue
+		}
+		bySubtask[tc.SubtaskID.Int32] = append(bySubtask[tc.SubtaskID.Int32], tc)
+	}
+
+	var groups []testGroup
+	if len(ungrouped) > 0 {
+		groups = append(groups, testGroup{testCases: ungrouped})
+	}
+	for i := range subtasks {
+		groups = append(groups, testGroup{
+			subtask:   &subtasks[i],
+			testCases: bySubtask[subtasks[i].ID],
+		})
+	}
+
+	return groups
+}
+
+// subtaskSummary tells the player which subtasks they passed
+func subtaskSummary(results []events.SubtaskResult) string {
+	var passed, passedWeight, totalWeight int32
+	for _, st := range results {
+		totalWeight += st.Weight
+		if st.Passed {
+			passed++
+			passedWeight += st.Weight
+		}
+	}
+
+	return fmt.Sprintf("Passed %d/%d subtasks (%d/%d)", passed, len(results), passedWeight, totalWeight)
+}
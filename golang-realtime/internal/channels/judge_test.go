@@ -0,0 +1,65 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/executor"
+	"golang-realtime/internal/store"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+// fakeJudge answers every job with the same result
+type fakeJudge struct {
+	result executor.Result
+}
+
+func (j fakeJudge) ExecuteJob(lang store.Language, code string, input *string) executor.Result {
+	return j.result
+}
+
+func (j fakeJudge) ExecuteInteractiveJob(lang store.Language, code string, input *string, timeLimit time.Duration, interactor *executor.Interactor) executor.Result {
+	return j.result
+}
+
+// exitError runs a command that exits with code 3
+func exitError(t *testing.T) *exec.ExitError {
+	t.Helper()
+
+	var exitErr *exec.ExitError
+	if err := exec.Command("sh", "-c", "exit 3").Run(); !errors.As(err, &exitErr) {
+		t.Fatalf("want an exit error, got %v", err)
+	}
+	return exitErr
+}
+
+func TestRunTestCaseJudgesExecutorErrors(t *testing.T) {
+	exitErr := exitError(t)
+
+	tests := []struct {
+		name string
+		err  error
+		want events.JudgeStatus
+	}{
+		{"non-zero exit", exitErr, events.RuntimeError},
+		{"build failed", fmt.Errorf("%w: %w", executor.ErrCompilationFailed, exitErr), events.CompilationError},
+		{"deadline", fmt.Errorf("%w: %w", context.DeadlineExceeded, exitErr), events.TimeLimitExceeded},
+		{"queue full", executor.ErrJobQueueFull, events.JudgementFailed},
+		{"no container", executor.ErrNoContainerAvailable, events.JudgementFailed},
+		{"container state", executor.ErrContainerNotFound, events.JudgementFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rm := NewRoomManager(1, store.New(newFakeDB()), nil)
+			rm.worker = fakeJudge{result: executor.Result{Error: tt.err, Output: "panic: boom", ExitCode: 3}}
+
+			status, _ := rm.runTestCase(store.Language{}, "package main", store.TestCase{ID: 1, ExpectedOutput: "42"}, nil)
+			if status != tt.want {
+				t.Errorf("status = %s, want %s", status, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package channels
+
+import (
+	"cmp"
+	"context"
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
+	"slices"
+)
+
+// broadcastLeaderboard sends the standings players may see to the room, along with what changed since the last ones.
+// Nothing is sent while they stay the same, e.g. for results judged during the freeze
+func (rm *RoomManager) broadcastLeaderboard() {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeoutSecond)
+	defer cancel()
+
+	rows, frozen, err := rm.visibleLeaderboard(ctx)
+	if err != nil {
+		rm.logger.Error("failed to get leaderboard to broadcast", "room_id", rm.RoomId, "error", err)
+		return
+	}
+
+	rm.standingsMu.Lock()
+	defer rm.standingsMu.Unlock()
+
+	payload := events.LeaderboardPayload{
+		Frozen:  frozen,
+		Entries: make([]events.LeaderboardEntry, 0, len(rows)),
+		Changed: make([]events.LeaderboardEntry, 0),
+		Removed: make([]int32, 0),
+	}
+
+	standings := make(map[int32]events.LeaderboardEntry, len(rows))
+	for _, row := range rows {
+		entry := events.LeaderboardEntry{
+			PlayerID:   row.PlayerID,
+			PlayerName: row.Name,
+			Score:      row.Score.Int32,
+			Place:      row.Place.Int32,
+			Penalty:    row.Penalty,
+		}
+
+		previous, ok := rm.standings[row.PlayerID]
+		if ok {
+			entry.Delta = entry.Score - previous.Score
+		}
+		if !ok || !sameStanding(previous, entry) {
+			payload.Changed = append(payload.Changed, entry)
+		}
+
+		standings[row.PlayerID] = entry
+		payload.Entries = append(payload.Entries, entry)
+	}
+	for playerID := range rm.standings {
+		if _, ok := standings[playerID]; !ok {
+			payload.Removed = append(payload.Removed, playerID)
+		}
+	}
+
+	if rm.standingsRevision > 0 && len(payload.Changed) == 0 && len(payload.Removed) == 0 {
+		return
+	}
+
+	rm.standings = standings
+	rm.standingsFrozen = frozen
+	rm.standingsRevision++
+	payload.Revision = rm.standingsRevision
+
+	rm.dispatchEvent(events.NewSseEvent(events.LEADERBOARD_UPDATED, rm.RoomId, payload))
+}
+
+// currentLeaderboard returns the standings last sent to the room, for a new client to apply the next updates on.
+// Changed and Removed are left empty
+func (rm *RoomManager) currentLeaderboard(ctx context.Context) (events.LeaderboardPayload, error) {
+	rm.standingsMu.Lock()
+	defer rm.standingsMu.Unlock()
+
+	payload := events.LeaderboardPayload{
+		Revision: rm.standingsRevision,
+		Frozen:   rm.standingsFrozen,
+		Entries:  make([]events.LeaderboardEntry, 0, len(rm.standings)),
+		Changed:  make([]events.LeaderboardEntry, 0),
+		Removed:  make([]int32, 0),
+	}
+
+	if rm.standingsRevision > 0 {
+		for _, entry := range rm.standings {
+			payload.Entries = append(payload.Entries, entry)
+		}
+		slices.SortFunc(payload.Entries, func(a, b events.LeaderboardEntry) int {
+			return cmp.Or(cmp.Compare(a.Place, b.Place), cmp.Compare(a.PlayerID, b.PlayerID))
+		})
+		return payload, nil
+	}
+
+	// nothing was sent yet
+	rows, frozen, err := rm.visibleLeaderboard(ctx)
+	if err != nil {
+		return payload, err
+	}
+	payload.Frozen = frozen
+	for _, row := range rows {
+		payload.Entries = append(payload.Entries, events.LeaderboardEntry{
+			PlayerID:   row.PlayerID,
+			PlayerName: row.Name,
+			Score:      row.Score.Int32,
+			Place:      row.Place.Int32,
+			Penalty:    row.Penalty,
+		})
+	}
+
+	return payload, nil
+}
+
+// visibleLeaderboard returns the standings of the freeze start while the scoreboard is frozen, the live ones otherwise
+func (rm *RoomManager) visibleLeaderboard(ctx context.Context) ([]store.GetLeaderboardForRoomRow, bool, error) {
+	if standings, frozen := rm.Frozen(); frozen {
+		return standings.Leaderboard, true, nil
+	}
+
+	rows, err := rm.queries.GetLeaderboardForRoom(ctx, rm.RoomId)
+	return rows, false, err
+}
+
+// sameStanding reports whether the player's row of the leaderboard did not change, the delta aside
+func sameStanding(a, b events.LeaderboardEntry) bool {
+	return a.PlayerName == b.PlayerName && a.Score == b.Score && a.Place == b.Place && a.Penalty == b.Penalty
+}
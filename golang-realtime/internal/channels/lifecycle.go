@@ -0,0 +1,225 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// RoomStatus is the stage of the match played in a room
+type RoomStatus string
+
+// lobby -> countdown -> running -> finished -> archived
+const (
+	StatusLobby     RoomStatus = "lobby"
+	StatusCountdown RoomStatus = "countdown"
+	StatusRunning   RoomStatus = "running"
+	StatusFinished  RoomStatus = "finished"
+	StatusArchived  RoomStatus = "archived"
+)
+
+// Player states in a room, see note.md
+const (
+	PlayerPresent      = "PRESENT"
+	PlayerDisconnected = "DISCONNECTED"
+	PlayerLeft         = "LEFT"
+	PlayerCompleted    = "COMPLETED"
+)
+
+const (
+	DefaultCountdown = 10 * time.Second
+)
+
+var (
+	ErrInvalidTransition = errors.New("invalid room state transition")
+	ErrRoomNotRunning    = errors.New("room is not running")
+)
+
+// StartMatch moves the room from the lobby to the countdown, the match starts once it elapses.
+// Rooms asking for a draw get their questions now, in the same transaction: the room is either
+// counting down with its whole question set or still in the lobby with none.
+// The start time is kept with the status so a restart resumes the countdown where it was
+func (rm *RoomManager) StartMatch(ctx context.Context, countdown time.Duration) (store.Room, error) {
+	var room store.Room
+	var drawn int
+	startsAt := time.Now().Add(countdown)
+	err := rm.queries.InTx(ctx, func(q *store.Queries) error {
+		// the lock holds a concurrent start back until this one is done, it then finds the room counting down
+		locked, err := q.GetRoomForUpdate(ctx, rm.RoomId)
+		if err != nil {
+			return err
+		}
+		if RoomStatus(locked.Status) != StatusLobby {
+			return fmt.Errorf("%w: room is not %s", ErrInvalidTransition, StatusLobby)
+		}
+
+		if drawn, err = rm.drawQuestions(ctx, q, locked); err != nil {
+			return err
+		}
+
+		room, err = q.CountdownRoom(ctx, store.CountdownRoomParams{
+			ID:       rm.RoomId,
+			StartsAt: pgtype.Timestamp{Time: startsAt, Valid: true},
+		})
+		return err
+	})
+	if err != nil {
+		return room, err
+	}
+
+	if drawn > 0 {
+		// the cached question set is stale, it is read again on the next submission
+		rm.settingsMu.Lock()
+		rm.settings = nil
+		rm.settingsMu.Unlock()
+	}
+
+	rm.schedule(max(time.Until(startsAt), 0), events.CountdownEnded{RoomId: rm.RoomId})
+
+	rm.logger.Info("room countdown started", "room_id", rm.RoomId, "starts_at", startsAt)
+
+	rm.dispatchEvent(events.NewSseEvent(events.ROOM_COUNTDOWN, rm.RoomId, events.CountdownPayload{
+		RoomID:   rm.RoomId,
+		StartsAt: startsAt,
+	}))
+
+	return room, nil
+}
+
+// FinishMatch ends a running match before its time is up
+func (rm *RoomManager) FinishMatch(ctx context.Context) (store.Room, error) {
+	return rm.finish(ctx)
+}
+
+// ArchiveRoom puts a finished room away, it is kept for its results only
+func (rm *RoomManager) ArchiveRoom(ctx context.Context) (store.Room, error) {
+	return rm.transition(ctx, StatusFinished, StatusArchived)
+}
+
+// processCountdownEnded starts the match, the end timer is set if the room has a duration
+func (rm *RoomManager) processCountdownEnded(event events.CountdownEnded) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeoutSecond)
+	defer cancel()
+
+	room, err := rm.queries.GetRoom(ctx, event.RoomId)
+	if err != nil {
+		return err
+	}
+
+	startedAt := time.Now()
+	var endsAt pgtype.Timestamp
+	if room.DurationMinutes > 0 {
+		endsAt = pgtype.Timestamp{
+			Time:  startedAt.Add(time.Duration(room.DurationMinutes) * time.Minute),
+			Valid: true,
+		}
+	}
+
+	room, err = rm.queries.StartRoom(ctx, store.StartRoomParams{
+		ID:        event.RoomId,
+		StartedAt: pgtype.Timestamp{Time: startedAt, Valid: true},
+		EndsAt:    endsAt,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		// the room left the countdown in the meantime
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if endsAt.Valid {
+		rm.schedule(endsAt.Time.Sub(startedAt), events.MatchEnded{RoomId: rm.RoomId})
+	}
+
+	rm.logger.Info("room started", "room_id", rm.RoomId, "ends_at", endsAt.Time)
+
+	data := events.MatchStartedPayload{
+		RoomID:    rm.RoomId,
+		StartedAt: startedAt,
+	}
+	if endsAt.Valid {
+		data.EndsAt = &endsAt.Time
+	}
+
+	rm.dispatchEvent(events.NewSseEvent(events.ROOM_STARTED, rm.RoomId, data))
+
+	return nil
+}
+
+// processMatchEnded finishes the match once its time is up
+func (rm *RoomManager) processMatchEnded(event events.MatchEnded) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeoutSecond)
+	defer cancel()
+
+	_, err := rm.finish(ctx)
+	if errors.Is(err, ErrInvalidTransition) {
+		// finished by hand already
+		return nil
+	}
+	return err
+}
+
+// finish moves a running room to finished, the players still around are marked as completed
+func (rm *RoomManager) finish(ctx context.Context) (store.Room, error) {
+	room, err := rm.transition(ctx, StatusRunning, StatusFinished)
+	if err != nil {
+		return room, err
+	}
+
+	rm.stopTimer()
+
+	if err := rm.queries.CompleteRoomPlayers(ctx, rm.RoomId); err != nil {
+		rm.logger.Error("failed to complete room players", "room_id", rm.RoomId, "error", err)
+	}
+
+	rm.logger.Info("room finished", "room_id", rm.RoomId)
+
+	rm.dispatchEvent(events.NewSseEvent(events.ROOM_FINISHED, rm.RoomId, events.RoomPayload{RoomID: rm.RoomId}))
+
+	return room, nil
+}
+
+// transition atomically moves the room from one state to another
+func (rm *RoomManager) transition(ctx context.Context, from, to RoomStatus) (store.Room, error) {
+	room, err := rm.queries.UpdateRoomStatus(ctx, store.UpdateRoomStatusParams{
+		NewStatus: string(to),
+		ID:        rm.RoomId,
+		OldStatus: string(from),
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return room, fmt.Errorf("%w: room is not %s", ErrInvalidTransition, from)
+	}
+	return room, err
+}
+
+// schedule sends the event to the room's queue once d has elapsed, replacing any pending timer
+func (rm *RoomManager) schedule(d time.Duration, event events.Event) {
+	rm.timerMu.Lock()
+	defer rm.timerMu.Unlock()
+
+	if rm.timer != nil {
+		rm.timer.Stop()
+	}
+	rm.timer = time.AfterFunc(d, func() {
+		if err := rm.Publish(event); err != nil {
+			rm.logger.Warn("timer fired after the room stopped", "room_id", rm.RoomId, "event", event)
+		}
+	})
+}
+
+func (rm *RoomManager) stopTimer() {
+	rm.timerMu.Lock()
+	defer rm.timerMu.Unlock()
+
+	if rm.timer != nil {
+		rm.timer.Stop()
+		rm.timer = nil
+	}
+}
@@ -0,0 +1,126 @@
+package channels
+
+import (
+	"expvar"
+	"golang-realtime/internal/events"
+	"time"
+)
+
+const (
+	// DefaultListenerBuffer is how many events a connection may have waiting before it misses some
+	DefaultListenerBuffer = 64
+	// DefaultHeartbeatInterval is how often an idle connection gets a comment, so proxies keep it open
+	DefaultHeartbeatInterval = 15 * time.Second
+	// DefaultSlowConsumerTimeout is how long the queue of a connection may stay full before the room drops it
+	DefaultSlowConsumerTimeout = 10 * time.Second
+
+	slowConsumerReason = "connection too slow to follow the room, reconnect to catch up"
+	kickedReason       = "kicked from the room"
+)
+
+// streamMetrics are published under "sse" at /debug/vars: open connections, events sent and dropped,
+// leaderboard updates coalesced on replay and slow consumers dropped
+var streamMetrics = expvar.NewMap("sse")
+
+// StreamOptions tune the event streams of the clients
+type StreamOptions struct {
+	BufferSize          int
+	HeartbeatInterval   time.Duration
+	SlowConsumerTimeout time.Duration
+}
+
+// DefaultStreamOptions returns the options used when none are configured
+func DefaultStreamOptions() StreamOptions {
+	return StreamOptions{
+		BufferSize:          DefaultListenerBuffer,
+		HeartbeatInterval:   DefaultHeartbeatInterval,
+		SlowConsumerTimeout: DefaultSlowConsumerTimeout,
+	}
+}
+
+// Listener is a client connection following the events of a room, a player may have several
+type Listener struct {
+	ID          int64 // set by the room when it starts listening
+	PlayerID    int32
+	events      chan events.SseEvent
+	dropped     chan struct{} // closed once the room gave up on the listener
+	reason      string
+	slowTimeout time.Duration
+	fullSince   time.Time // when the queue was found full, zero while events get through. Protected by the room's Mu
+}
+
+// NewListener creates the listener of a new connection, Close must be called once the connection ends
+func NewListener(playerID int32, opts StreamOptions) *Listener {
+	streamMetrics.Add("connections", 1)
+	return &Listener{
+		PlayerID:    playerID,
+		events:      make(chan events.SseEvent, opts.BufferSize),
+		dropped:     make(chan struct{}),
+		slowTimeout: opts.SlowConsumerTimeout,
+	}
+}
+
+// Events are the events sent to the listener, in order
+func (l *Listener) Events() <-chan events.SseEvent {
+	return l.events
+}
+
+// Dropped is closed once the room stopped sending to the listener, Reason tells why
+func (l *Listener) Dropped() <-chan struct{} {
+	return l.dropped
+}
+
+func (l *Listener) Reason() string {
+	return l.reason
+}
+
+// Close counts the connection as gone, the caller must have removed the listener from the room
+func (l *Listener) Close() {
+	streamMetrics.Add("connections", -1)
+}
+
+// offer queues the event without waiting. A missed event is replayed along with the next one that gets through,
+// the listener is only given up on once its queue stayed full for longer than its timeout
+func (l *Listener) offer(e events.SseEvent) (slow bool) {
+	select {
+	case l.events <- e:
+		l.fullSince = time.Time{}
+		streamMetrics.Add("events_sent", 1)
+		return false
+	default:
+	}
+
+	streamMetrics.Add("events_dropped", 1)
+	if l.fullSince.IsZero() {
+		l.fullSince = time.Now()
+		return false
+	}
+	return time.Since(l.fullSince) > l.slowTimeout
+}
+
+// drop ends the listener, the caller must have removed it from the room
+func (l *Listener) drop(reason string) {
+	l.reason = reason
+	close(l.dropped)
+}
+
+// coalesce keeps only the last LEADERBOARD_UPDATED of the events, each of them holds the full standings
+func coalesce(missed []events.SseEvent) []events.SseEvent {
+	last := -1
+	for i, e := range missed {
+		if e.EventType == events.LEADERBOARD_UPDATED {
+			last = i
+		}
+	}
+
+	coalesced := make([]events.SseEvent, 0, len(missed))
+	for i, e := range missed {
+		if e.EventType == events.LEADERBOARD_UPDATED && i != last {
+			streamMetrics.Add("leaderboard_coalesced", 1)
+			continue
+		}
+		coalesced = append(coalesced, e)
+	}
+
+	return coalesced
+}
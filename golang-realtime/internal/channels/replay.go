@@ -0,0 +1,189 @@
+package channels
+
+import (
+	"golang-realtime/internal/events"
+	"time"
+)
+
+const (
+	// DefaultEventHistory is how many of the last events of a room are kept for reconnecting clients
+	DefaultEventHistory = 256
+)
+
+// sentEvent is an event sent to the room, or only to the player when playerID is set
+type sentEvent struct {
+	event    events.SseEvent
+	playerID int32
+}
+
+// eventHistory is a ring buffer of the last events sent to the room
+type eventHistory struct {
+	entries []sentEvent
+	next    int // oldest entry, overwritten by the next one once the buffer is full
+}
+
+func newEventHistory(size int) eventHistory {
+	return eventHistory{entries: make([]sentEvent, 0, size)}
+}
+
+func (h *eventHistory) add(e sentEvent) {
+	if len(h.entries) < cap(h.entries) {
+		h.entries = append(h.entries, e)
+		return
+	}
+	h.entries[h.next] = e
+	h.next = (h.next + 1) % len(h.entries)
+}
+
+// since returns the events after id that the player may see, oldest first,
+// along with the id of the oldest event still kept, 0 if none is
+func (h *eventHistory) since(id int64, playerID int32) ([]events.SseEvent, int64) {
+	if len(h.entries) == 0 {
+		return nil, 0
+	}
+
+	ordered := make([]sentEvent, 0, len(h.entries))
+	ordered = append(ordered, h.entries[h.next:]...)
+	ordered = append(ordered, h.entries[:h.next]...)
+
+	missed := make([]events.SseEvent, 0)
+	for _, e := range ordered {
+		if e.event.ID <= id {
+			continue
+		}
+		if e.playerID != 0 && e.playerID != playerID {
+			continue
+		}
+		missed = append(missed, e.event)
+	}
+
+	return missed, ordered[0].event.ID
+}
+
+// firstEventID is where the event ids of a new manager start. Ids of a room keep growing across managers,
+// so the id a client got from an evicted or restarted manager is never mistaken for one of the current manager
+func firstEventID() int64 {
+	return time.Now().UnixMicro()
+}
+
+// send numbers the event, keeps it for replay and hands it to the listeners it is meant for, all of them if playerID is 0.
+// Listeners are never waited for: one that is not ready misses the event and gets it replayed along with the next one,
+// one that stays behind for too long is dropped
+func (rm *RoomManager) send(e events.SseEvent, playerID int32) {
+	rm.Mu.Lock()
+	defer rm.Mu.Unlock()
+
+	rm.lastEventID++
+	e.ID = rm.lastEventID
+	rm.history.add(sentEvent{event: e, playerID: playerID})
+
+	for id, listener := range rm.Listerners {
+		if playerID != 0 && listener.PlayerID != playerID {
+			continue
+		}
+
+		if slow := listener.offer(e); slow {
+			rm.logger.Warn("dropping slow listener", "player_id", listener.PlayerID, "connection_id", id, "room_id", rm.RoomId, "event_id", e.ID)
+			streamMetrics.Add("slow_consumers_dropped", 1)
+			delete(rm.Listerners, id)
+			listener.drop(slowConsumerReason)
+		}
+	}
+}
+
+// Resume tells a new listener where it starts from
+type Resume struct {
+	// Missed are the events to send first, Complete is false when some of them are no longer kept
+	// and the client should fetch the room again
+	Missed   []events.SseEvent
+	Complete bool
+	// LastEventID is the id of the last event sent before the listener was registered, it gets every one after it
+	LastEventID int64
+	// FirstConnection is set when the player had no other connection to the room
+	FirstConnection bool
+}
+
+// Listen registers the listener as a new connection of its player.
+// A reconnecting client passes the id of the last event it got as lastEventID to get the events it missed since
+func (rm *RoomManager) Listen(listener *Listener, lastEventID int64) Resume {
+	rm.Mu.Lock()
+	defer rm.Mu.Unlock()
+
+	resume := Resume{
+		Complete:        true,
+		LastEventID:     rm.lastEventID,
+		FirstConnection: !rm.connectedLocked(listener.PlayerID),
+	}
+
+	rm.nextListenerID++
+	listener.ID = rm.nextListenerID
+	rm.Listerners[listener.ID] = listener
+
+	if lastEventID == 0 || lastEventID >= rm.lastEventID {
+		return resume
+	}
+
+	missed, oldest := rm.history.since(lastEventID, listener.PlayerID)
+	resume.Missed = coalesce(missed)
+	// ids follow each other, the client only missed kept events if it saw the one right before the oldest
+	resume.Complete = oldest != 0 && lastEventID >= oldest-1
+
+	return resume
+}
+
+// Unlisten removes the listener, the other connections of the player stay
+func (rm *RoomManager) Unlisten(listener *Listener) {
+	rm.Mu.Lock()
+	defer rm.Mu.Unlock()
+
+	delete(rm.Listerners, listener.ID)
+}
+
+// disconnect drops every connection of the player, each gets reason as its last event
+func (rm *RoomManager) disconnect(playerID int32, reason string) {
+	rm.Mu.Lock()
+	defer rm.Mu.Unlock()
+
+	for id, listener := range rm.Listerners {
+		if listener.PlayerID == playerID {
+			delete(rm.Listerners, id)
+			listener.drop(reason)
+		}
+	}
+}
+
+// connected reports whether the player has at least one connection to the room
+func (rm *RoomManager) connected(playerID int32) bool {
+	rm.Mu.RLock()
+	defer rm.Mu.RUnlock()
+
+	return rm.connectedLocked(playerID)
+}
+
+// connectedLocked is connected for callers holding Mu
+func (rm *RoomManager) connectedLocked(playerID int32) bool {
+	for _, listener := range rm.Listerners {
+		if listener.PlayerID == playerID {
+			return true
+		}
+	}
+	return false
+}
+
+// Replay returns the kept events between after and before, ends excluded, that the player may see.
+// Listeners use it to make up for the events they were not ready for, only the last leaderboard update is kept
+func (rm *RoomManager) Replay(playerID int32, after, before int64) []events.SseEvent {
+	rm.Mu.RLock()
+	defer rm.Mu.RUnlock()
+
+	missed, _ := rm.history.since(after, playerID)
+
+	replay := make([]events.SseEvent, 0, len(missed))
+	for _, e := range missed {
+		if e.ID < before {
+			replay = append(replay, e)
+		}
+	}
+
+	return coalesce(replay)
+}
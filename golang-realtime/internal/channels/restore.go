@@ -0,0 +1,144 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// LoadRooms starts a manager for every room that is not archived and picks up where the last run left off
+func (gr *GlobalRooms) LoadRooms(ctx context.Context) error {
+	rooms, err := gr.queries.ListActiveRooms(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, room := range rooms {
+		rm := NewRoomManager(room.ID, gr.queries, gr.worker)
+		if err := rm.restore(ctx, room); err != nil {
+			return err
+		}
+
+		gr.Mu.Lock()
+		gr.register(rm)
+		gr.Mu.Unlock()
+
+		go rm.Start()
+	}
+
+	gr.logger.Info("rooms loaded", "count", len(rooms))
+
+	return nil
+}
+
+// GetOrLoadRoom returns the manager of the room, starting one if the room is in the database but not in memory,
+// e.g. after it was evicted for being idle. Archived rooms get ErrRoomClosed
+func (gr *GlobalRooms) GetOrLoadRoom(ctx context.Context, roomId int32) (*RoomManager, error) {
+	if rm := gr.GetRoomById(roomId); rm != nil {
+		return rm, nil
+	}
+
+	// loading under the lock keeps two requests from starting a manager each
+	gr.Mu.Lock()
+	defer gr.Mu.Unlock()
+
+	if rm, ok := gr.Rooms[roomId]; ok {
+		return rm, nil
+	}
+
+	room, err := gr.queries.GetRoom(ctx, roomId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if RoomStatus(room.Status) == StatusArchived {
+		return nil, ErrRoomClosed
+	}
+
+	rm := NewRoomManager(room.ID, gr.queries, gr.worker)
+	if err := rm.restore(ctx, room); err != nil {
+		return nil, err
+	}
+	gr.register(rm)
+
+	go rm.Start()
+
+	return rm, nil
+}
+
+// restore rebuilds the in-memory state of a room from the database,
+// the pending countdown or match end is scheduled again from the times kept on the room
+func (rm *RoomManager) restore(ctx context.Context, room store.Room) error {
+	// no SSE connection survives a restart, players show up again when they reconnect
+	if err := rm.queries.DisconnectRoomPlayers(ctx, room.ID); err != nil {
+		return err
+	}
+
+	switch RoomStatus(room.Status) {
+	case StatusCountdown:
+		countdown := DefaultCountdown
+		if room.StartsAt.Valid {
+			// a countdown that ran out while the server was down starts the match right away
+			countdown = max(time.Until(room.StartsAt.Time), 0)
+		}
+		rm.schedule(countdown, events.CountdownEnded{RoomId: room.ID})
+
+	case StatusRunning:
+		if err := rm.restoreSubmissionCounts(ctx); err != nil {
+			return err
+		}
+
+		if room.EndsAt.Valid {
+			// a match that ran out while the server was down ends right away
+			rm.schedule(max(time.Until(room.EndsAt.Time), 0), events.MatchEnded{RoomId: room.ID})
+		}
+
+		if err := rm.restoreFreeze(ctx); err != nil {
+			return err
+		}
+		if _, err := rm.freezeIfDue(ctx, time.Now()); err != nil {
+			return err
+		}
+
+	case StatusFinished:
+		// the results kept back wait for their reveal
+		if err := rm.restoreFreeze(ctx); err != nil {
+			return err
+		}
+	}
+
+	rm.logger.Info("room restored", "room_id", room.ID, "status", room.Status)
+
+	return nil
+}
+
+// restoreSubmissionCounts counts the scored submissions of every player from their attempts and solves,
+// so a restart does not hand out fresh submissions in rooms that limit them
+func (rm *RoomManager) restoreSubmissionCounts(ctx context.Context) error {
+	questionScores, err := rm.queries.ListRoomQuestionScores(ctx, rm.RoomId)
+	if err != nil {
+		return err
+	}
+	solves, err := rm.queries.ListRoomSolves(ctx, rm.RoomId)
+	if err != nil {
+		return err
+	}
+
+	rm.settingsMu.Lock()
+	defer rm.settingsMu.Unlock()
+
+	for _, qs := range questionScores {
+		rm.submissions[submissionKey{playerID: qs.PlayerID, questionID: qs.QuestionID}] += qs.WrongAttempts
+	}
+	for _, solve := range solves {
+		rm.submissions[submissionKey{playerID: solve.PlayerID, questionID: solve.QuestionID}]++
+	}
+
+	return nil
+}
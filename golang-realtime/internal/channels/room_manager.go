@@ -2,16 +2,21 @@ package channels
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"golang-realtime/internal/events"
 	"golang-realtime/internal/executor"
+	"golang-realtime/internal/scoring"
 	service "golang-realtime/internal/services"
 	"golang-realtime/internal/store"
 	"log/slog"
+	"os/exec"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
@@ -19,19 +24,54 @@ const (
 	DefaultQueryTimeoutSecond = 10 * time.Second
 )
 
+// judge runs solutions against test cases, the executor's worker pool does it outside of tests
+type judge interface {
+	ExecuteJob(lang store.Language, code string, input *string) executor.Result
+	ExecuteInteractiveJob(lang store.Language, code string, input *string, timeLimit time.Duration, interactor *executor.Interactor) executor.Result
+}
+
 // event-based
 // each room will have a room manager, acting as a broadcaster for room-related events to all connected clients
 // events is a single queue that received events from multiple sources and process it, then send to all listeners
 // listeners are all the clients connected to the room, represented by their client IDs
 type RoomManager struct {
-	RoomId        int32
-	Events        chan any
-	Listerners    map[int32]chan<- events.SseEvent
-	worker        *executor.WorkerPool
-	logger        *slog.Logger
-	queries       *store.Queries
-	Mu            sync.RWMutex // Protects Listerners map
-	leaderboardMu sync.Mutex   // Protects leaderboard calculation
+	RoomId         int32
+	Events         chan events.Event
+	Listerners     map[int64]*Listener // by connection id
+	worker         judge
+	logger         *slog.Logger
+	queries        *store.Queries
+	Mu             sync.RWMutex // Protects Listerners, nextListenerID, history and lastEventID
+	nextListenerID int64
+	history        eventHistory // last events sent, for clients to catch up on reconnect
+	lastEventID    int64
+	leaderboardMu  sync.Mutex   // Protects leaderboard calculation
+	freezeMu       sync.RWMutex // Protects freeze and freezeLifted
+	freeze         *scoreboardFreeze
+	freezeLifted   bool         // every frozen result has been revealed, the room never freezes again
+	timerMu        sync.Mutex   // Protects timer
+	timer          *time.Timer  // countdown or match end, whichever is next
+	settingsMu     sync.RWMutex // Protects settings, submissions and lastWrong
+	settings       *RoomSettings
+	submissions    map[submissionKey]int32     // scored submissions per player and question
+	lastWrong      map[submissionKey]time.Time // last wrong answer per player and question
+	done           chan struct{}               // closed once the manager is stopped
+	stopOnce       sync.Once
+	started        atomic.Bool                          // the event loop runs, a second one would process events out of order
+	onStop         func(*RoomManager)                   // removes the manager from the registry
+	lastActive     atomic.Int64                         // unix nano of the last event or listener
+	judgeMu        sync.Mutex                           // Protects judgeQueues
+	judgeQueues    map[int32][]events.SolutionSubmitted // submissions waiting per player, a key means the player's judge is running
+	handlers       map[events.Kind]eventHandler
+	subsMu         sync.Mutex // Protects subs and nextSubID
+	subs           []subscription
+	nextSubID      int
+	// leaderboardChanged is set by the handler of an event that recalculated the leaderboard, only used on the event loop
+	leaderboardChanged bool
+	standingsMu        sync.Mutex                        // Protects standings, standingsFrozen and standingsRevision
+	standings          map[int32]events.LeaderboardEntry // last standings sent to the room, by player
+	standingsFrozen    bool
+	standingsRevision  int64
 }
 
 // basically, GlobalRooms struct holds all the RoomManagers (channel) of each room
@@ -44,20 +84,10 @@ type GlobalRooms struct {
 	Rooms map[int32]*RoomManager
 }
 
+// NewGlobalRooms creates an empty registry, LoadRooms brings back the rooms of the database
 func NewGlobalRooms(queries *store.Queries, logger *slog.Logger, worker *executor.WorkerPool) *GlobalRooms {
-	// Initialize testing rooms for development
-	rooms := map[int32]*RoomManager{
-		1: NewRoomManager(1, queries, worker),
-		2: NewRoomManager(2, queries, worker),
-		3: NewRoomManager(3, queries, worker),
-	}
-
-	for _, rm := range rooms {
-		go rm.Start()
-	}
-
 	return &GlobalRooms{
-		Rooms:   rooms,
+		Rooms:   make(map[int32]*RoomManager),
 		worker:  worker,
 		logger:  logger,
 		queries: queries,
@@ -73,140 +103,66 @@ func (gr *GlobalRooms) GetRoomById(roomId int32) *RoomManager {
 func (gr *GlobalRooms) CreateRoom(roomId int32, queries *store.Queries) *RoomManager {
 	rm := NewRoomManager(roomId, queries, gr.worker)
 	gr.Mu.Lock()
-	gr.Rooms[roomId] = rm
+	gr.register(rm)
 	gr.Mu.Unlock()
 	go rm.Start() // Start the room manager
 	return rm
 }
 
 func NewRoomManager(roomId int32, queries *store.Queries, worker *executor.WorkerPool) *RoomManager {
-	return &RoomManager{
+	rm := &RoomManager{
 		RoomId:        roomId,
-		Events:        make(chan any, 10),
-		Listerners:    make(map[int32]chan<- events.SseEvent),
+		Events:        make(chan events.Event, 10),
+		Listerners:    make(map[int64]*Listener),
+		history:       newEventHistory(DefaultEventHistory),
+		lastEventID:   firstEventID(),
 		logger:        slog.Default(),
 		queries:       queries,
 		Mu:            sync.RWMutex{},
 		leaderboardMu: sync.Mutex{}, // Initialize the new mutex
 		worker:        worker,
+		submissions:   make(map[submissionKey]int32),
+		lastWrong:     make(map[submissionKey]time.Time),
+		done:          make(chan struct{}),
+		judgeQueues:   make(map[int32][]events.SolutionSubmitted),
+		handlers:      make(map[events.Kind]eventHandler),
 	}
+	rm.registerHandlers()
+	rm.touch()
+	return rm
 }
 
+// Start runs the event loop of the room until it stops, a room has a single loop
 func (rm *RoomManager) Start() {
-	for event := range rm.Events {
-		switch e := event.(type) {
-		case events.SolutionSubmitted:
-			if err := rm.processSolutionSubmitted(e); err != nil {
-				rm.logger.Error("failed to process solution submitted event", "error", err)
-			}
-
-		case events.SolutionResult:
-			if err := rm.processSolutionResult(e); err != nil {
-				rm.logger.Error("failed to process correct solution result event", "error", err)
-			}
+	if !rm.started.CompareAndSwap(false, true) {
+		rm.logger.Error("room manager started twice", "room_id", rm.RoomId)
+		return
+	}
 
-		case events.PlayerJoined:
-			if err := rm.processPlayerJoined(e); err != nil {
-				rm.logger.Error("failed to process player joined event", "error", err)
-			}
-		case events.PlayerLeft:
-			if err := rm.processPlayerLeft(e); err != nil {
-				rm.logger.Error("failed to process player left event", "error", err)
-			}
-		case events.RoomDeleted:
-			if err := rm.processRoomDeleted(e); err != nil {
-				rm.logger.Error("failed to process room deleted event", "error", err)
-			}
+	for {
+		select {
+		case <-rm.done:
+			rm.drain()
+			return
+		case event := <-rm.Events:
+			rm.touch()
+			rm.handle(event)
 		}
 	}
 }
 
+// dispatchEvent sends the event to every listener of the room
 func (rm *RoomManager) dispatchEvent(e events.SseEvent) {
-	// Safely copy listeners to avoid race conditions
-	rm.Mu.RLock()
-	if rm.Listerners == nil {
-		rm.Mu.RUnlock()
-		rm.logger.Warn("no listeners map found")
-		return
-	}
-
-	listeners := make(map[int32]chan<- events.SseEvent)
-	for pid, listener := range rm.Listerners {
-		listeners[pid] = listener
-	}
-	rm.Mu.RUnlock()
-
-	rm.logger.Info("Hit dispatchEvent()",
-		"Number of Listeners", len(listeners),
-		"Event", e)
-
-	for playerId, listener := range listeners {
-		// Capture the listener variable properly
-		go func(l chan<- events.SseEvent, pid int32) {
-			defer func() {
-				if r := recover(); r != nil {
-					rm.logger.Error("panic while dispatching event", "error", r, "player_id", pid, "event", e)
-				}
-			}()
-
-			rm.logger.Info("dispatching to", "player_id", pid)
-			select {
-			case l <- e:
-				// Successfully sent
-				rm.logger.Info("event sent to", "player_id", pid)
-			default:
-				// Channel is full or closed, log but don't block
-				rm.logger.Warn("failed to send event to listener - channel full or closed", "player_id", pid)
-			}
-		}(listener, playerId)
-	}
+	rm.send(e, 0)
 }
 
+// dispatchEventToPlayer sends the event to the listener of the player only
 func (rm *RoomManager) dispatchEventToPlayer(e events.SseEvent, playerID int32) {
-	rm.Mu.RLock()
-	if rm.Listerners == nil {
-		rm.Mu.RUnlock()
-		rm.logger.Warn("no listeners map found")
-		return
-	}
-
-	// find the target listener
-	var listener chan<- events.SseEvent
-	for pid, l := range rm.Listerners {
-		if pid == playerID {
-			listener = l
-		}
-	}
-
-	if listener == nil {
-		rm.logger.Error("listener not found", "player_id", playerID)
-		rm.Mu.RUnlock()
-		return
-	}
-
-	rm.Mu.RUnlock()
-
-	// Capture the listener variable properly
-	go func(l chan<- events.SseEvent, pid int32) {
-		defer func() {
-			if r := recover(); r != nil {
-				rm.logger.Error("panic while dispatching event", "error", r, "player_id", pid, "event", e)
-			}
-		}()
-
-		rm.logger.Info("dispatching to", "player_id", pid)
-		select {
-		case l <- e:
-			// Successfully sent
-			rm.logger.Info("event sent to", "player_id", pid)
-		default:
-			// Channel is full or closed, log but don't block
-			rm.logger.Warn("failed to send event to listener - channel full or closed", "player_id", pid)
-		}
-	}(listener, playerID)
+	rm.send(e, playerID)
 }
 
 // TODO: Rewrite processSolutionSubmitted and processSolutionResult
+// processSolutionSubmitted runs on the judge of the player, never on the event loop
 func (rm *RoomManager) processSolutionSubmitted(event events.SolutionSubmitted) error {
 	ctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeoutSecond)
 	defer cancel()
@@ -228,55 +184,271 @@ func (rm *RoomManager) processSolutionSubmitted(event events.SolutionSubmitted)
 		return err
 	}
 
-	testCases, err := rm.queries.ListTestCasesForQuestion(ctx, event.QuestionId)
+	room, err := rm.queries.GetRoom(ctx, rm.RoomId)
+	if err != nil {
+		return err
+	}
+
+	// the handler already checks it, but the match may have ended while the submission was queued
+	if RoomStatus(room.Status) != StatusRunning {
+		return ErrRoomNotRunning
+	}
+
+	feedbackLevel := FeedbackLevel(room.FeedbackLevel)
+
+	questionScore, err := rm.questionPoints(ctx, question)
+	if err != nil {
+		return err
+	}
+
+	var testCases []store.TestCase
+	if event.SampleOnly {
+		// samples are public, so the player always gets the full diff
+		feedbackLevel = FeedbackSampleDiff
+		testCases, err = rm.queries.ListSampleTestCasesForQuestion(ctx, event.QuestionId)
+	} else {
+		testCases, err = rm.queries.ListTestCasesForQuestion(ctx, event.QuestionId)
+	}
+	if err != nil {
+		return err
+	}
+
+	interactor, err := rm.getInteractor(ctx, event.QuestionId)
 	if err != nil {
+		rm.logger.Error("Error", "interactor", err)
 		return err
 	}
 
 	finalCode := combineCodeWithTemplate(question.TemplateFunction.String, event.Code, getLanguagePlaceHolder(normalizedLang))
 	rm.logger.Info("Code and Templated combined!", "final_code", finalCode)
 
-	// TODO: Execute Job with input (test cases)
-	// i for test cases number
+	subtasks, err := rm.queries.ListSubtasksForQuestion(ctx, event.QuestionId)
+	if err != nil {
+		return err
+	}
+	if event.SampleOnly {
+		// sample runs are never scored, so there is nothing to split
+		subtasks = nil
+	}
+
+	// test numbers shown to the player follow the order of the test cases, not the judging order
+	testNumbers := make(map[int32]int, len(testCases))
 	for i, tc := range testCases {
-		rm.logger.Info("Testing...", "test_case", tc)
-		result := rm.worker.ExecuteJob(lang, finalCode, &tc.Input)
-		if result.Error != nil {
-			rm.Events <- events.SolutionResult{
-				SolutionSubmitted: event,
-				Status:            events.RuntimeError,
-				Message:           result.Output,
-			}
+		testNumbers[tc.ID] = i + 1
+	}
 
-			// This error is the user solution's fault, so we don't return it
-			return nil
+	var failure *events.SolutionResult
+	var subtaskResults []events.SubtaskResult
+	samplesPassed, subtasksPassed := true, true
+	for _, group := range groupTestCases(subtasks, testCases) {
+		passed := true
+		if group.subtask != nil && len(group.testCases) == 0 {
+			// a subtask without tests proves nothing, so it is never worth its points
+			rm.logger.Warn("subtask has no test cases", "question_id", event.QuestionId, "subtask_id", group.subtask.ID)
+			passed = false
 		}
+		for _, tc := range group.testCases {
+			// a stopped room has nobody left to judge for
+			if rm.stopped() {
+				return ErrRoomStopped
+			}
+			rm.logger.Info("Testing...", "test_case", tc)
 
-		// TODO: Compare output
-		actualOutput := strings.TrimSpace(result.Output)
-		expectedOutput := strings.TrimSpace(tc.ExpectedOutput)
-		if actualOutput != expectedOutput {
-			message := fmt.Sprintf("Input:%v, Expected Output:%v, Actual Output: %v", tc.Input, tc.ExpectedOutput, result.Output)
-			rm.logger.Warn("Output not match", "message", message)
-			rm.Events <- events.SolutionResult{
-				SolutionSubmitted: event,
-				Status:            events.WrongAnswer,
-				Message:           message,
+			status, output := rm.runTestCase(lang, finalCode, tc, interactor)
+			if status == events.Accepted {
+				continue
+			}
+			if status == events.JudgementFailed {
+				// the judge broke down, none of the tests tell anything about the solution
+				return rm.Publish(events.SolutionResult{
+					SolutionSubmitted: event,
+					Status:            events.JudgementFailed,
+					Message:           "The solution could not be judged, try again",
+					QuestionScore:     questionScore,
+					Difficulty:        question.Difficulty,
+				})
 			}
 
 			// This error is the user solution's fault, so we don't return it
-			return nil
+			passed = false
+			if failure == nil {
+				failure = &events.SolutionResult{
+					SolutionSubmitted: event,
+					Status:            status,
+					Message:           feedbackMessage(feedbackLevel, status, testNumbers[tc.ID], tc, output),
+				}
+			}
+			// the rest of the group can't change the outcome
+			break
 		}
-		rm.logger.Info("placeholder:%v", "i", i)
+
+		if group.subtask == nil {
+			samplesPassed = samplesPassed && passed
+			continue
+		}
+
+		subtasksPassed = subtasksPassed && passed
+		subtaskResults = append(subtaskResults, events.SubtaskResult{
+			Name:   group.subtask.Name,
+			Weight: group.subtask.Weight,
+			Passed: passed,
+		})
 	}
 
-	rm.Events <- events.SolutionResult{
-		SolutionSubmitted: event,
-		Status:            events.Accepted,
-		Message:           "Solution accepted",
+	if failure == nil && subtasksPassed {
+		return rm.Publish(events.SolutionResult{
+			SolutionSubmitted: event,
+			Status:            events.Accepted,
+			Message:           "Solution accepted",
+			Subtasks:          subtaskResults,
+			QuestionScore:     questionScore,
+			Difficulty:        question.Difficulty,
+			LanguageID:        lang.ID,
+		})
 	}
 
-	return nil
+	if failure == nil {
+		// every test passed, but some subtask had none
+		failure = &events.SolutionResult{
+			SolutionSubmitted: event,
+			Status:            events.JudgementFailed,
+			Message:           "Some subtasks of the question have no tests",
+		}
+	}
+
+	failure.Subtasks = subtaskResults
+	failure.QuestionScore = questionScore
+	failure.Difficulty = question.Difficulty
+	// subtasks only earn points once the tests outside of them, usually the samples, pass
+	if samplesPassed && events.SubtaskRatio(subtaskResults) > 0 {
+		failure.Message = fmt.Sprintf("%v\n%v", subtaskSummary(subtaskResults), failure.Message)
+		failure.Status = events.PartiallyAccepted
+	}
+	return rm.Publish(*failure)
+}
+
+// runTestCase judges the solution against a single test case,
+// the output is what the player may get to see when the test fails
+func (rm *RoomManager) runTestCase(lang store.Language, code string, tc store.TestCase, interactor *executor.Interactor) (events.JudgeStatus, string) {
+	if interactor != nil {
+		result := rm.worker.ExecuteInteractiveJob(lang, code, &tc.Input, testCaseTimeLimit(lang, tc), interactor)
+		return interactiveVerdict(result), result.Output
+	}
+
+	result := rm.worker.ExecuteJob(lang, code, &tc.Input)
+	if result.Error != nil {
+		// a program that fails is reported as an error too, only the judge failing is a failed judgement
+		status := executionVerdict(result.Error)
+		if status == events.JudgementFailed {
+			rm.logger.Error("test case could not be run", "test_case_id", tc.ID, "error", result.Error)
+			return status, ""
+		}
+		return status, result.Output
+	}
+
+	// TODO: Compare output
+	actualOutput := strings.TrimSpace(result.Output)
+	expectedOutput := strings.TrimSpace(tc.ExpectedOutput)
+	if actualOutput != expectedOutput {
+		rm.logger.Warn("Output not match",
+			"test_case_id", tc.ID,
+			"expected_output", tc.ExpectedOutput,
+			"actual_output", result.Output)
+		return events.WrongAnswer, result.Output
+	}
+
+	return events.Accepted, result.Output
+}
+
+// getInteractor returns the interactor of an interactive question, nil if the question is a regular one
+func (rm *RoomManager) getInteractor(ctx context.Context, questionID int32) (*executor.Interactor, error) {
+	interactor, err := rm.queries.GetInteractor(ctx, questionID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lang, err := rm.queries.GetLanguage(ctx, interactor.LanguageID)
+	if err != nil {
+		return nil, err
+	}
+
+	timeLimit := executor.CodeRunTimeOutSecond
+	if interactor.TimeLimitSecond.Valid {
+		timeLimit = secondsToDuration(interactor.TimeLimitSecond.Float64)
+	}
+
+	return &executor.Interactor{
+		Language:  lang,
+		Code:      interactor.SourceCode,
+		TimeLimit: timeLimit,
+	}, nil
+}
+
+// testCaseTimeLimit picks the test case's own time constraint, falling back to the language's timeout
+func testCaseTimeLimit(lang store.Language, tc store.TestCase) time.Duration {
+	switch {
+	case tc.TimeConstraint.Valid:
+		return secondsToDuration(tc.TimeConstraint.Float64)
+	case lang.TimeoutSecond.Valid:
+		return secondsToDuration(lang.TimeoutSecond.Float64)
+	default:
+		return executor.CodeRunTimeOutSecond
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// interactiveVerdict maps the outcome of an interactive run to a judge status,
+// the interactor's exit code decides unless one of the sides ran out of time
+func interactiveVerdict(result executor.Result) events.JudgeStatus {
+	if errors.Is(result.Error, executor.ErrCompilationFailed) {
+		return events.CompilationError
+	}
+	if result.Error != nil || result.Interaction == nil {
+		return events.JudgementFailed
+	}
+
+	interaction := result.Interaction
+	switch {
+	case interaction.ProgramTimedOut:
+		return events.TimeLimitExceeded
+	case interaction.InteractorTimedOut:
+		return events.JudgementFailed
+	}
+
+	switch interaction.InteractorExitCode {
+	case executor.InteractorAccepted:
+		if interaction.ProgramExitCode != 0 {
+			return events.RuntimeError
+		}
+		return events.Accepted
+	case executor.InteractorWrongAnswer, executor.InteractorPresentationError:
+		return events.WrongAnswer
+	default:
+		return events.JudgementFailed
+	}
+}
+
+// executionVerdict judges a run the executor returned an error for. The program failing to build,
+// exiting with a non-zero code or being killed at the deadline is on the player,
+// anything else means the judge could not run it
+func executionVerdict(err error) events.JudgeStatus {
+	var exitErr *exec.ExitError
+	switch {
+	case errors.Is(err, executor.ErrCompilationFailed):
+		return events.CompilationError
+	case errors.Is(err, context.DeadlineExceeded):
+		return events.TimeLimitExceeded
+	case errors.As(err, &exitErr):
+		return events.RuntimeError
+	default:
+		return events.JudgementFailed
+	}
 }
 
 // combineCodeWithTemplate combined the userCode and templateFunction at placeHolder
@@ -291,25 +463,103 @@ func (rm *RoomManager) processSolutionResult(e events.SolutionResult) error {
 
 	rm.logger.Info("processSoltuionResult() hit", "event", e)
 
+	// sample runs are only for the submitter's eyes and never scored
+	if e.SolutionSubmitted.SampleOnly {
+		sseEvent := events.NewSseEvent(events.SAMPLE_RUN_RESULT, rm.RoomId, rm.verdict(ctx, e))
+
+		rm.dispatchEventToPlayer(sseEvent, e.SolutionSubmitted.PlayerId)
+
+		return nil
+	}
+
+	// results of the final minutes are kept back from the room until they are revealed
+	frozen, err := rm.freezeIfDue(ctx, e.SolutionSubmitted.SubmittedTime)
+	if err != nil {
+		return err
+	}
+
 	//
 	if e.Status != events.Accepted {
 		rm.logger.Info("solution failed", "event", e)
-		sseEvent := events.SseEvent{
-			EventType: events.WRONG_SOLUTION_SUBMITTED,
-			Data:      fmt.Sprintf("status:%v,message:%v", e.Status, e.Message),
-		}
+		sseEvent := events.NewSseEvent(events.WRONG_SOLUTION_SUBMITTED, rm.RoomId, rm.verdict(ctx, e))
 
 		// send compilation error to the player
-		go rm.dispatchEventToPlayer(sseEvent, e.SolutionSubmitted.PlayerId)
+		rm.dispatchEventToPlayer(sseEvent, e.SolutionSubmitted.PlayerId)
+
+		if e.Status != events.PartiallyAccepted {
+			if err := rm.recordWrongAttempt(ctx, e); err != nil {
+				return err
+			}
+			if frozen {
+				return rm.queuePendingResult(ctx, e)
+			}
+			return nil
+		}
+	}
 
+	// a solved question is never scored again, later submissions only get a verdict
+	_, err = rm.queries.GetRoomSolve(ctx, store.GetRoomSolveParams{
+		RoomID:     e.SolutionSubmitted.RoomId,
+		PlayerID:   e.SolutionSubmitted.PlayerId,
+		QuestionID: e.SolutionSubmitted.QuestionId,
+	})
+	if err == nil {
+		if e.Status == events.Accepted {
+			rm.notifyAlreadySolved(ctx, e)
+		}
 		return nil
 	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return err
+	}
 
-	rm.queries.AddRoomPlayerScore(ctx, store.AddRoomPlayerScoreParams{
-		RoomID:      e.SolutionSubmitted.RoomId,
-		PlayerID:    e.SolutionSubmitted.PlayerId,
-		ScoreTooAdd: pgtype.Int4{Int32: 50},
+	// the verdict tells how much the room score of the player moved
+	verdict := rm.verdict(ctx, e)
+
+	// every fully passed subtask earns its share of the question's points,
+	// only the best submission of each question counts towards the room score
+	points, penalty, err := rm.scoreSubmission(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	if e.Status == events.Accepted {
+		solved, err := rm.recordSolve(ctx, e, points, penalty)
+		if err != nil {
+			return err
+		}
+		if !solved {
+			rm.notifyAlreadySolved(ctx, e)
+			return nil
+		}
+	}
+
+	_, err = rm.queries.UpsertRoomQuestionScore(ctx, store.UpsertRoomQuestionScoreParams{
+		RoomID:     e.SolutionSubmitted.RoomId,
+		PlayerID:   e.SolutionSubmitted.PlayerId,
+		QuestionID: e.SolutionSubmitted.QuestionId,
+		BestScore:  points,
 	})
+	if err != nil {
+		return err
+	}
+
+	if e.Status == events.PartiallyAccepted {
+		if err := rm.recordWrongAttempt(ctx, e); err != nil {
+			return err
+		}
+	}
+
+	roomPlayer, err := rm.queries.RefreshRoomPlayerScore(ctx, store.RefreshRoomPlayerScoreParams{
+		RoomID:   e.SolutionSubmitted.RoomId,
+		PlayerID: e.SolutionSubmitted.PlayerId,
+	})
+	if err != nil {
+		return err
+	}
+	verdict.Points = points
+	verdict.ScoreDelta = roomPlayer.Score.Int32 - verdict.Score
+	verdict.Score = roomPlayer.Score.Int32
 
 	// Recalculate leaderboard after score update
 	if err := rm.calculateLeaderboard(ctx); err != nil {
@@ -317,17 +567,176 @@ func (rm *RoomManager) processSolutionResult(e events.SolutionResult) error {
 		// non-fatal, but should be monitored
 	}
 
-	sseEvent := events.SseEvent{
-		EventType: events.CORRECT_SOLUTION_SUBMITTED,
-		Data:      "",
+	sseEvent := events.NewSseEvent(events.CORRECT_SOLUTION_SUBMITTED, rm.RoomId, verdict)
+	if e.Status == events.PartiallyAccepted {
+		sseEvent.EventType = events.PARTIAL_SOLUTION_SUBMITTED
 	}
 
-	// send event to the whole room
-	go rm.dispatchEvent(sseEvent)
+	if frozen {
+		if err := rm.queuePendingResult(ctx, e); err != nil {
+			return err
+		}
+
+		// only the submitter learns the verdict, the room sees it on reveal
+		rm.dispatchEventToPlayer(sseEvent, e.SolutionSubmitted.PlayerId)
+		return nil
+	}
+
+	// send event to the whole room, the judge's feedback is only for the submitter
+	verdict.Message = ""
+	sseEvent.Data = verdict
+	rm.dispatchEvent(sseEvent)
 
 	return nil
 }
 
+// scoreSubmission asks the room's scoring strategy how many points the submission is worth,
+// the penalty time is only set by strategies that rank with it
+func (rm *RoomManager) scoreSubmission(ctx context.Context, e events.SolutionResult) (int32, int32, error) {
+	room, err := rm.queries.GetRoom(ctx, rm.RoomId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	strategy, err := scoring.New(scoring.Mode(room.ScoringMode))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var wrongAttempts int32
+	previous, err := rm.queries.GetRoomQuestionScore(ctx, store.GetRoomQuestionScoreParams{
+		RoomID:     e.SolutionSubmitted.RoomId,
+		PlayerID:   e.SolutionSubmitted.PlayerId,
+		QuestionID: e.SolutionSubmitted.QuestionId,
+	})
+	switch {
+	case err == nil:
+		wrongAttempts = previous.WrongAttempts
+	case !errors.Is(err, pgx.ErrNoRows):
+		return 0, 0, err
+	}
+
+	solvers, err := rm.queries.CountRoomQuestionSolvers(ctx, store.CountRoomQuestionSolversParams{
+		RoomID:     e.SolutionSubmitted.RoomId,
+		QuestionID: e.SolutionSubmitted.QuestionId,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	submission := scoring.Submission{
+		QuestionScore:  e.QuestionScore,
+		Difficulty:     e.Difficulty,
+		ScoreRatio:     e.ScoreRatio(),
+		SubmittedAt:    e.SolutionSubmitted.SubmittedTime,
+		MatchStartedAt: room.StartedAt.Time,
+		WrongAttempts:  wrongAttempts,
+		FirstSolve:     solvers == 0,
+	}
+
+	var penalty int32
+	if p, ok := strategy.(scoring.PenaltyStrategy); ok {
+		penalty = p.Penalty(submission)
+	}
+
+	return strategy.Score(submission), penalty, nil
+}
+
+// recordWrongAttempt counts a rejected submission, judge failures are not the player's fault
+// and give the submission back
+func (rm *RoomManager) recordWrongAttempt(ctx context.Context, e events.SolutionResult) error {
+	if e.Status == events.JudgementFailed {
+		rm.RefundSubmission(e.SolutionSubmitted.PlayerId, e.SolutionSubmitted.QuestionId)
+		return nil
+	}
+
+	rm.recordWrongAnswer(e.SolutionSubmitted.PlayerId, e.SolutionSubmitted.QuestionId, time.Now())
+
+	return rm.queries.RecordRoomQuestionWrongAttempt(ctx, store.RecordRoomQuestionWrongAttemptParams{
+		RoomID:     e.SolutionSubmitted.RoomId,
+		PlayerID:   e.SolutionSubmitted.PlayerId,
+		QuestionID: e.SolutionSubmitted.QuestionId,
+	})
+}
+
+// recordSolve stores the accepted submission and adds it to the solve ledger, in one transaction so a
+// duplicate leaves nothing behind. It returns false if the player had already solved the question,
+// e.g. two accepted submissions judged at once
+func (rm *RoomManager) recordSolve(ctx context.Context, e events.SolutionResult, points, penalty int32) (bool, error) {
+	err := rm.queries.InTx(ctx, func(q *store.Queries) error {
+		submissionID, err := q.CreateAcceptedSubmission(ctx, store.CreateAcceptedSubmissionParams{
+			SourceCode: pgtype.Text{String: e.SolutionSubmitted.Code, Valid: true},
+			LanguageID: pgtype.Int4{Int32: e.LanguageID, Valid: true},
+			Message:    pgtype.Text{String: e.Message, Valid: true},
+			CreatedAt:  pgtype.Timestamp{Time: e.SolutionSubmitted.SubmittedTime, Valid: true},
+		})
+		if err != nil {
+			return err
+		}
+
+		// no row means the question was solved already, rolling back drops the submission
+		_, err = q.CreateRoomSolve(ctx, store.CreateRoomSolveParams{
+			RoomID:        e.SolutionSubmitted.RoomId,
+			PlayerID:      e.SolutionSubmitted.PlayerId,
+			QuestionID:    e.SolutionSubmitted.QuestionId,
+			SubmissionID:  pgtype.Int4{Int32: submissionID, Valid: true},
+			SolvedAt:      pgtype.Timestamp{Time: e.SolutionSubmitted.SubmittedTime, Valid: true},
+			AwardedPoints: points,
+			Penalty:       penalty,
+		})
+		return err
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// notifyAlreadySolved tells the player their submission is correct but worth no more points
+func (rm *RoomManager) notifyAlreadySolved(ctx context.Context, e events.SolutionResult) {
+	verdict := rm.verdict(ctx, e)
+	verdict.Message = "Question already solved, no points awarded"
+
+	rm.dispatchEventToPlayer(events.NewSseEvent(events.QUESTION_ALREADY_SOLVED, rm.RoomId, verdict), e.SolutionSubmitted.PlayerId)
+}
+
+// verdict builds the payload of a judged submission, with the room score of the player as it is now
+func (rm *RoomManager) verdict(ctx context.Context, e events.SolutionResult) events.VerdictPayload {
+	verdict := events.VerdictPayload{
+		PlayerID:    e.SolutionSubmitted.PlayerId,
+		PlayerName:  rm.playerName(ctx, e.SolutionSubmitted.PlayerId),
+		QuestionID:  e.SolutionSubmitted.QuestionId,
+		Language:    e.SolutionSubmitted.Language,
+		Status:      e.Status,
+		Message:     e.Message,
+		SubmittedAt: e.SolutionSubmitted.SubmittedTime,
+	}
+
+	roomPlayer, err := rm.queries.GetRoomPlayer(ctx, store.GetRoomPlayerParams{
+		RoomID:   rm.RoomId,
+		PlayerID: e.SolutionSubmitted.PlayerId,
+	})
+	if err == nil {
+		verdict.Score = roomPlayer.Score.Int32
+	}
+
+	return verdict
+}
+
+// playerName returns the name of the player, empty if it can't be read
+func (rm *RoomManager) playerName(ctx context.Context, playerID int32) string {
+	player, err := rm.queries.GetPlayer(ctx, playerID)
+	if err != nil {
+		rm.logger.Warn("failed to get player name", "player_id", playerID, "error", err)
+		return ""
+	}
+	return player.Name
+}
+
 // Helper method to check if player is in room
 func (rm *RoomManager) playerInRoom(ctx context.Context, roomID, playerID int32) bool {
 	_, err := rm.queries.GetRoomPlayer(ctx, store.GetRoomPlayerParams{
@@ -357,6 +766,47 @@ func (rm *RoomManager) addPlayerToRoom(ctx context.Context, roomID, playerID int
 	return err
 }
 
+// checkSeatAvailable tells whether a new player can still join the room
+func (rm *RoomManager) checkSeatAvailable(ctx context.Context, room store.Room) error {
+	if RoomStatus(room.Status) == StatusArchived {
+		return ErrRoomClosed
+	}
+	if room.MaxPlayers <= 0 {
+		return nil
+	}
+
+	players, err := rm.queries.CountRoomPlayers(ctx, room.ID)
+	if err != nil {
+		return err
+	}
+	if players >= int64(room.MaxPlayers) {
+		return ErrRoomFull
+	}
+
+	return nil
+}
+
+// Helper method to get the state of a player in the room, empty if they are not in it
+func (rm *RoomManager) playerState(ctx context.Context, playerID int32) string {
+	roomPlayer, err := rm.queries.GetRoomPlayer(ctx, store.GetRoomPlayerParams{
+		RoomID:   rm.RoomId,
+		PlayerID: playerID,
+	})
+	if err != nil {
+		return ""
+	}
+	return roomPlayer.State.String
+}
+
+// Helper method to set the state of a player in the room
+func (rm *RoomManager) setPlayerState(ctx context.Context, playerID int32, state string) error {
+	return rm.queries.UpdateRoomPlayerState(ctx, store.UpdateRoomPlayerStateParams{
+		RoomID:   rm.RoomId,
+		PlayerID: playerID,
+		State:    pgtype.Text{String: state, Valid: true},
+	})
+}
+
 // Helper method to remove player from room
 func (rm *RoomManager) removePlayerFromRoom(ctx context.Context, roomID, playerID int32) error {
 	return rm.queries.DeleteRoomPlayer(ctx, store.DeleteRoomPlayerParams{
@@ -382,6 +832,10 @@ func (rm *RoomManager) calculateLeaderboard(ctx context.Context) error {
 	}
 
 	rm.logger.Info("Finished calculating leaderboard for room", "room_id", rm.RoomId)
+
+	// observers hear about it once the event that caused it is handled
+	rm.leaderboardChanged = true
+
 	return nil
 }
 
@@ -394,7 +848,17 @@ func (rm *RoomManager) processPlayerJoined(event events.PlayerJoined) error {
 		return err
 	}
 
+	room, err := rm.queries.GetRoom(ctx, rm.RoomId)
+	if err != nil {
+		return err
+	}
+
 	if !rm.playerInRoom(ctx, event.RoomID, event.PlayerID) {
+		// joins go through the room's queue one at a time, so the seat count can't be raced
+		if err := rm.checkSeatAvailable(ctx, room); err != nil {
+			return err
+		}
+
 		rm.logger.Info("player is not in room, adding to room...",
 			"player", player,
 			"room", event.RoomID)
@@ -403,6 +867,16 @@ func (rm *RoomManager) processPlayerJoined(event events.PlayerJoined) error {
 			rm.logger.Error("failed to add player to room", "error", err)
 			return err
 		}
+	} else if rm.playerState(ctx, event.PlayerID) == PlayerLeft {
+		return ErrPlayerKicked
+	}
+
+	// players coming back after the match keep their COMPLETED state
+	switch RoomStatus(room.Status) {
+	case StatusLobby, StatusCountdown, StatusRunning:
+		if err := rm.setPlayerState(ctx, event.PlayerID, PlayerPresent); err != nil {
+			rm.logger.Error("failed to set player state", "error", err)
+		}
 	}
 
 	// Recalculate leaderboard after a player joins
@@ -414,14 +888,12 @@ func (rm *RoomManager) processPlayerJoined(event events.PlayerJoined) error {
 
 	rm.logger.Info("player joined", "event", event)
 
-	data := fmt.Sprintf("playerId:%d,roomId:%d\n\n", event.PlayerID, rm.RoomId)
-
-	sseEvent := events.SseEvent{
-		EventType: events.PLAYER_JOINED,
-		Data:      data,
-	}
+	sseEvent := events.NewSseEvent(events.PLAYER_JOINED, rm.RoomId, events.PlayerPayload{
+		PlayerID:   player.ID,
+		PlayerName: player.Name,
+	})
 
-	go rm.dispatchEvent(sseEvent)
+	rm.dispatchEvent(sseEvent)
 
 	return nil
 }
@@ -429,12 +901,38 @@ func (rm *RoomManager) processPlayerJoined(event events.PlayerJoined) error {
 func (rm *RoomManager) processPlayerLeft(event events.PlayerLeft) error {
 	ctx := context.Background()
 
+	// the player is only gone once their last connection closed
+	if rm.connected(event.PlayerId) {
+		rm.logger.Info("player still connected to the room", "event", event)
+		return nil
+	}
+
 	// Process the player left event
-	data := fmt.Sprintf("playerId:%d,roomId:%d\n\n", event.PlayerId, rm.RoomId)
+	data := events.PlayerPayload{
+		PlayerID:   event.PlayerId,
+		PlayerName: rm.playerName(ctx, event.PlayerId),
+	}
 
-	err := rm.removePlayerFromRoom(ctx, event.RoomId, event.PlayerId)
+	room, err := rm.queries.GetRoom(ctx, rm.RoomId)
 	if err != nil {
-		rm.logger.Error("failed to remove player from room", "error", err)
+		return err
+	}
+
+	// once the match has started, players are kept in the room along with their results
+	switch RoomStatus(room.Status) {
+	case StatusLobby:
+		err = rm.removePlayerFromRoom(ctx, event.RoomId, event.PlayerId)
+		if err != nil {
+			rm.logger.Error("failed to remove player from room", "error", err)
+		}
+	case StatusCountdown, StatusRunning:
+		if rm.playerState(ctx, event.PlayerId) == PlayerLeft {
+			break
+		}
+		err = rm.setPlayerState(ctx, event.PlayerId, PlayerDisconnected)
+		if err != nil {
+			rm.logger.Error("failed to set player state", "error", err)
+		}
 	}
 
 	// Recalculate leaderboard after a player leaves
@@ -443,39 +941,62 @@ func (rm *RoomManager) processPlayerLeft(event events.PlayerLeft) error {
 		rm.logger.Error("failed to calculate leaderboard after player left", "error", err)
 	}
 
-	sseEvent := events.SseEvent{
-		EventType: events.PLAYER_LEFT,
-		Data:      data,
-	}
+	sseEvent := events.NewSseEvent(events.PLAYER_LEFT, rm.RoomId, data)
 
-	go rm.dispatchEvent(sseEvent)
+	rm.dispatchEvent(sseEvent)
 	rm.logger.Info("player left", "event", event)
 
 	return nil
 }
 
-// TODO: Complete this shit
-func (rm *RoomManager) processRoomDeleted(event events.RoomDeleted) error {
-	rm.Mu.Lock()
-	defer rm.Mu.Unlock()
+// processPlayerKicked removes the player from the lobby, or marks them as LEFT once the match has started.
+// Kicked players keep their results but can't submit anymore
+func (rm *RoomManager) processPlayerKicked(event events.PlayerKicked) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeoutSecond)
+	defer cancel()
 
-	ctx := context.Background()
+	room, err := rm.queries.GetRoom(ctx, rm.RoomId)
+	if err != nil {
+		return err
+	}
 
-	// Process the room deleted event
-	data := fmt.Sprintf("roomId:%d\n\n", rm.RoomId)
+	if RoomStatus(room.Status) == StatusLobby {
+		err = rm.removePlayerFromRoom(ctx, event.RoomId, event.PlayerId)
+	} else {
+		err = rm.setPlayerState(ctx, event.PlayerId, PlayerLeft)
+	}
+	if err != nil {
+		return err
+	}
 
-	sseEvent := events.SseEvent{
-		EventType: events.ROOM_DELETED,
-		Data:      data,
+	if err := rm.calculateLeaderboard(ctx); err != nil {
+		rm.logger.Error("failed to calculate leaderboard after player kicked", "error", err)
 	}
 
+	rm.logger.Info("player kicked", "event", event)
+
+	// the kicked player gets the event too, as the last one before their connections close
+	rm.dispatchEvent(events.NewSseEvent(events.PLAYER_KICKED, rm.RoomId, events.PlayerPayload{
+		PlayerID:   event.PlayerId,
+		PlayerName: rm.playerName(ctx, event.PlayerId),
+	}))
+	rm.disconnect(event.PlayerId, kickedReason)
+
+	return nil
+}
+
+// processRoomDeleted removes the room and stops its manager, listeners get ROOM_DELETED as their last event
+func (rm *RoomManager) processRoomDeleted(event events.RoomDeleted) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeoutSecond)
+	defer cancel()
+
 	err := rm.queries.DeleteRoom(ctx, event.RoomId)
 	if err != nil {
-		rm.logger.Error("failed to delete room from database", "error", err)
+		return err
 	}
 	rm.logger.Info("room deleted", "roomID", event.RoomId)
 
-	go rm.dispatchEvent(sseEvent)
+	rm.Stop(events.NewSseEvent(events.ROOM_DELETED, rm.RoomId, events.RoomPayload{RoomID: rm.RoomId}))
 
 	return nil
 }
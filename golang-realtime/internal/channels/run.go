@@ -0,0 +1,53 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"golang-realtime/internal/executor"
+	service "golang-realtime/internal/services"
+	"golang-realtime/internal/store"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	ErrLanguageNotFound = errors.New("language not found")
+	ErrQuestionNotFound = errors.New("question not found")
+)
+
+// RunCode executes the player's code merged with the question's template against a custom input.
+// Nothing is scored, the result goes straight back to the caller
+func (gr *GlobalRooms) RunCode(ctx context.Context, questionID int32, language, code string, input *string) (executor.Result, error) {
+	normalizedLang := service.NormalizeLanguage(language)
+
+	lang, err := gr.queries.GetLanguageByName(ctx, normalizedLang)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return executor.Result{}, ErrLanguageNotFound
+	}
+	if err != nil {
+		return executor.Result{}, err
+	}
+
+	question, err := gr.queries.GetQuestion(ctx, store.GetQuestionParams{
+		ID:         questionID,
+		LanguageID: lang.ID,
+	})
+	if errors.Is(err, pgx.ErrNoRows) {
+		return executor.Result{}, ErrQuestionNotFound
+	}
+	if err != nil {
+		return executor.Result{}, err
+	}
+
+	finalCode := combineCodeWithTemplate(question.TemplateFunction.String, code, getLanguagePlaceHolder(normalizedLang))
+
+	result := gr.worker.ExecuteRunJob(ctx, lang, finalCode, input)
+	if errors.Is(result.Error, executor.ErrJobQueueFull) {
+		return result, result.Error
+	}
+	if err := ctx.Err(); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
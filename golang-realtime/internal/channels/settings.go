@@ -0,0 +1,334 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
+	"slices"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var (
+	ErrLanguageNotAllowed     = errors.New("language is not allowed in this room")
+	ErrQuestionNotInRoom      = errors.New("question is not part of this room")
+	ErrSubmissionLimitReached = errors.New("submission limit reached for this question")
+	ErrSubmissionCooldown     = errors.New("wait before submitting again after a wrong answer")
+	ErrSettingsLocked         = errors.New("scoring mode, duration and questions can only change in the lobby")
+)
+
+// RoomSettings is the part of the room's configuration checked on every submission.
+// Scoring mode, feedback level and duration are read from the room when they are used
+type RoomSettings struct {
+	// AllowedLanguages holds normalized language names, empty allows every language
+	AllowedLanguages []string
+	// QuestionIds is the question set of the room in order, empty allows the whole catalog
+	QuestionIds []int32
+	// MaxSubmissions per player and question, 0 means no limit
+	MaxSubmissions int32
+	// WrongAnswerCooldown a player waits on a question after a wrong answer
+	WrongAnswerCooldown time.Duration
+}
+
+// submissionKey identifies the submissions of a player on a question
+type submissionKey struct {
+	playerID   int32
+	questionID int32
+}
+
+func settingsFromRoom(room store.Room, questionIds []int32) RoomSettings {
+	return RoomSettings{
+		AllowedLanguages:    room.AllowedLanguages,
+		QuestionIds:         questionIds,
+		MaxSubmissions:      room.MaxSubmissions,
+		WrongAnswerCooldown: time.Duration(room.WrongAnswerCooldownSeconds) * time.Second,
+	}
+}
+
+// SettingsUpdate is a validated change of the room's settings, nil fields keep their current value.
+// Scoring mode, duration, draw and questions can only change in the lobby
+type SettingsUpdate struct {
+	Name                       *string
+	Description                *pgtype.Text
+	AllowedLanguages           *[]string
+	ScoringMode                *string
+	DurationMinutes            *int32
+	FeedbackLevel              *string
+	MaxSubmissions             *int32
+	WrongAnswerCooldownSeconds *int32
+	Draw                       *store.UpdateRoomDrawParams
+	// Questions replaces the question set, positions are taken from the order
+	Questions *[]store.AddRoomQuestionParams
+}
+
+func (u SettingsUpdate) lobbyOnly() bool {
+	return u.ScoringMode != nil || u.DurationMinutes != nil || u.Draw != nil || u.Questions != nil
+}
+
+// params applies the update on top of the room
+func (u SettingsUpdate) params(room store.Room) store.UpdateRoomParams {
+	params := store.UpdateRoomParams{
+		ID:                         room.ID,
+		Name:                       room.Name,
+		Description:                room.Description,
+		AllowedLanguages:           room.AllowedLanguages,
+		ScoringMode:                room.ScoringMode,
+		DurationMinutes:            room.DurationMinutes,
+		FeedbackLevel:              room.FeedbackLevel,
+		MaxSubmissions:             room.MaxSubmissions,
+		WrongAnswerCooldownSeconds: room.WrongAnswerCooldownSeconds,
+	}
+	if u.Name != nil {
+		params.Name = *u.Name
+	}
+	if u.Description != nil {
+		params.Description = *u.Description
+	}
+	if u.AllowedLanguages != nil {
+		params.AllowedLanguages = *u.AllowedLanguages
+	}
+	if u.ScoringMode != nil {
+		params.ScoringMode = *u.ScoringMode
+	}
+	if u.DurationMinutes != nil {
+		params.DurationMinutes = *u.DurationMinutes
+	}
+	if u.FeedbackLevel != nil {
+		params.FeedbackLevel = *u.FeedbackLevel
+	}
+	if u.MaxSubmissions != nil {
+		params.MaxSubmissions = *u.MaxSubmissions
+	}
+	if u.WrongAnswerCooldownSeconds != nil {
+		params.WrongAnswerCooldownSeconds = *u.WrongAnswerCooldownSeconds
+	}
+	return params
+}
+
+// kindSettingsRequested is only known to the room, handlers go through UpdateSettings
+const kindSettingsRequested events.Kind = "settings_requested"
+
+type settingsRequested struct {
+	roomID int32
+	update SettingsUpdate
+	done   chan<- settingsOutcome // buffered
+}
+
+type settingsOutcome struct {
+	room store.Room
+	err  error
+}
+
+func (e settingsRequested) Kind() events.Kind { return kindSettingsRequested }
+func (e settingsRequested) Room() int32       { return e.roomID }
+
+// UpdateSettings saves the update and returns the room as it now is. It runs on the room's event loop,
+// against the room as it is then, so an update can't slip in on a match that just started
+func (rm *RoomManager) UpdateSettings(ctx context.Context, update SettingsUpdate) (store.Room, error) {
+	done := make(chan settingsOutcome, 1)
+	if err := rm.Publish(settingsRequested{roomID: rm.RoomId, update: update, done: done}); err != nil {
+		return store.Room{}, err
+	}
+
+	select {
+	case outcome := <-done:
+		return outcome.room, outcome.err
+	case <-rm.done:
+		return store.Room{}, ErrRoomStopped
+	case <-ctx.Done():
+		return store.Room{}, ctx.Err()
+	}
+}
+
+func (rm *RoomManager) processSettingsRequested(e settingsRequested) error {
+	room, err := rm.saveSettings(e.update)
+	e.done <- settingsOutcome{room: room, err: err}
+	return err
+}
+
+// saveSettings writes the update in one transaction. The room stays locked until it is done,
+// so the match can't start halfway through
+func (rm *RoomManager) saveSettings(update SettingsUpdate) (store.Room, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeoutSecond)
+	defer cancel()
+
+	var updated store.Room
+	err := rm.queries.InTx(ctx, func(q *store.Queries) error {
+		room, err := q.GetRoomForUpdate(ctx, rm.RoomId)
+		if err != nil {
+			return err
+		}
+		if update.lobbyOnly() && RoomStatus(room.Status) != StatusLobby {
+			return ErrSettingsLocked
+		}
+
+		updated, err = q.UpdateRoom(ctx, update.params(room))
+		if err != nil {
+			return err
+		}
+
+		if update.Draw != nil {
+			draw := *update.Draw
+			draw.ID = rm.RoomId
+			updated, err = q.UpdateRoomDraw(ctx, draw)
+			if err != nil {
+				return err
+			}
+		}
+
+		if update.Questions != nil {
+			if err := q.DeleteRoomQuestions(ctx, rm.RoomId); err != nil {
+				return err
+			}
+			for i, question := range *update.Questions {
+				question.RoomID = rm.RoomId
+				question.Position = int32(i + 1)
+				if err := q.AddRoomQuestion(ctx, question); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return store.Room{}, err
+	}
+
+	questions, err := rm.queries.ListRoomQuestions(ctx, rm.RoomId)
+	if err != nil {
+		return store.Room{}, err
+	}
+	rm.applySettings(updated, RoomQuestionIds(questions))
+
+	return updated, nil
+}
+
+// applySettings swaps the room's settings for the ones just saved and tells the players about it
+func (rm *RoomManager) applySettings(room store.Room, questionIds []int32) {
+	settings := settingsFromRoom(room, questionIds)
+
+	rm.settingsMu.Lock()
+	rm.settings = &settings
+	rm.settingsMu.Unlock()
+
+	rm.logger.Info("room settings updated", "room_id", rm.RoomId, "settings", settings)
+
+	rm.dispatchEvent(events.NewSseEvent(events.ROOM_SETTINGS_UPDATED, rm.RoomId, events.RoomPayload{RoomID: rm.RoomId}))
+}
+
+// loadSettings returns the room's settings, they are read from the database the first time
+func (rm *RoomManager) loadSettings(ctx context.Context) (RoomSettings, error) {
+	rm.settingsMu.RLock()
+	settings := rm.settings
+	rm.settingsMu.RUnlock()
+	if settings != nil {
+		return *settings, nil
+	}
+
+	room, err := rm.queries.GetRoom(ctx, rm.RoomId)
+	if err != nil {
+		return RoomSettings{}, err
+	}
+	questions, err := rm.queries.ListRoomQuestions(ctx, rm.RoomId)
+	if err != nil {
+		return RoomSettings{}, err
+	}
+
+	loaded := settingsFromRoom(room, RoomQuestionIds(questions))
+
+	rm.settingsMu.Lock()
+	defer rm.settingsMu.Unlock()
+	if rm.settings == nil {
+		rm.settings = &loaded
+	}
+	return *rm.settings, nil
+}
+
+// AdmitSubmission checks a submission against the room's settings and counts it.
+// On a cooldown, the returned duration is how long the player still has to wait.
+// Sample runs are never scored, so they are neither limited nor counted
+func (rm *RoomManager) AdmitSubmission(ctx context.Context, playerID, questionID int32, language string, sampleOnly bool) (time.Duration, error) {
+	settings, err := rm.loadSettings(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(settings.QuestionIds) > 0 && !slices.Contains(settings.QuestionIds, questionID) {
+		return 0, ErrQuestionNotInRoom
+	}
+	if len(settings.AllowedLanguages) > 0 && !slices.Contains(settings.AllowedLanguages, language) {
+		return 0, ErrLanguageNotAllowed
+	}
+	if sampleOnly {
+		return 0, nil
+	}
+
+	key := submissionKey{playerID: playerID, questionID: questionID}
+
+	rm.settingsMu.Lock()
+	defer rm.settingsMu.Unlock()
+
+	if settings.WrongAnswerCooldown > 0 {
+		if last, ok := rm.lastWrong[key]; ok {
+			if wait := time.Until(last.Add(settings.WrongAnswerCooldown)); wait > 0 {
+				return wait, ErrSubmissionCooldown
+			}
+		}
+	}
+	if settings.MaxSubmissions > 0 && rm.submissions[key] >= settings.MaxSubmissions {
+		return 0, ErrSubmissionLimitReached
+	}
+
+	rm.submissions[key]++
+	return 0, nil
+}
+
+// RoomQuestionIds returns the ids of the room's question set, in order
+func RoomQuestionIds(questions []store.ListRoomQuestionsRow) []int32 {
+	ids := make([]int32, 0, len(questions))
+	for _, q := range questions {
+		ids = append(ids, q.QuestionID)
+	}
+	return ids
+}
+
+// questionPoints returns what the question is worth in this room, the question's own score unless the room overrides it
+func (rm *RoomManager) questionPoints(ctx context.Context, question store.Question) (int32, error) {
+	roomQuestion, err := rm.queries.GetRoomQuestion(ctx, store.GetRoomQuestionParams{
+		RoomID:     rm.RoomId,
+		QuestionID: question.ID,
+	})
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return question.Score, nil
+	case err != nil:
+		return 0, err
+	case roomQuestion.Points.Valid:
+		return roomQuestion.Points.Int32, nil
+	default:
+		return question.Score, nil
+	}
+}
+
+// recordWrongAnswer starts the cooldown of the player on the question
+func (rm *RoomManager) recordWrongAnswer(playerID, questionID int32, at time.Time) {
+	rm.settingsMu.Lock()
+	defer rm.settingsMu.Unlock()
+
+	rm.lastWrong[submissionKey{playerID: playerID, questionID: questionID}] = at
+}
+
+// RefundSubmission takes back a submission counted by AdmitSubmission that was never judged
+func (rm *RoomManager) RefundSubmission(playerID, questionID int32) {
+	rm.settingsMu.Lock()
+	defer rm.settingsMu.Unlock()
+
+	key := submissionKey{playerID: playerID, questionID: questionID}
+	if rm.submissions[key] > 0 {
+		rm.submissions[key]--
+	}
+}
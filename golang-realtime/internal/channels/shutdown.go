@@ -0,0 +1,178 @@
+package channels
+
+import (
+	"errors"
+	"golang-realtime/internal/events"
+	"sync"
+	"time"
+)
+
+const (
+	// listenerCloseTimeout is how long a listener gets to take the final event of a stopped room
+	listenerCloseTimeout = 2 * time.Second
+	// DefaultRoomIdleTimeout is how long a room without listeners or events stays in memory
+	DefaultRoomIdleTimeout = 30 * time.Minute
+)
+
+var (
+	ErrRoomStopped  = errors.New("room is no longer active")
+	ErrRoomNotFound = errors.New("room not found")
+)
+
+// Publish queues an event for the room's event loop, ErrRoomStopped once the manager is stopped
+func (rm *RoomManager) Publish(event events.Event) error {
+	select {
+	case <-rm.done:
+		return ErrRoomStopped
+	default:
+	}
+
+	select {
+	case rm.Events <- event:
+		return nil
+	case <-rm.done:
+		return ErrRoomStopped
+	}
+}
+
+// Done is closed once the manager is stopped
+func (rm *RoomManager) Done() <-chan struct{} {
+	return rm.done
+}
+
+func (rm *RoomManager) stopped() bool {
+	select {
+	case <-rm.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Stop shuts the manager down: timers are cancelled, every listener gets the final event before its stream closes,
+// the event loop ends and the room leaves the registry. Stopping twice does nothing
+func (rm *RoomManager) Stop(final events.SseEvent) {
+	rm.stopOnce.Do(func() {
+		rm.stopTimer()
+		rm.closeListeners(final)
+		close(rm.done)
+		rm.closeSubscriptions()
+
+		if rm.onStop != nil {
+			rm.onStop(rm)
+		}
+
+		rm.logger.Info("room manager stopped", "room_id", rm.RoomId, "event", final.EventType)
+	})
+}
+
+// closeListeners hands the final event to every listener and forgets them
+func (rm *RoomManager) closeListeners(final events.SseEvent) {
+	rm.Mu.Lock()
+	listeners := rm.Listerners
+	rm.Listerners = make(map[int64]*Listener)
+	rm.lastEventID++
+	final.ID = rm.lastEventID
+	rm.history.add(sentEvent{event: final})
+	rm.Mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, listener := range listeners {
+		wg.Add(1)
+		go func(l *Listener) {
+			defer wg.Done()
+
+			select {
+			case l.events <- final:
+			case <-time.After(listenerCloseTimeout):
+				rm.logger.Warn("listener did not take the final event", "player_id", l.PlayerID, "connection_id", l.ID)
+			}
+		}(listener)
+	}
+	wg.Wait()
+}
+
+// drain answers the events still queued when the manager stopped, nobody is left to process them
+func (rm *RoomManager) drain() {
+	for {
+		select {
+		case event := <-rm.Events:
+			if e, ok := event.(events.PlayerJoined); ok && e.Done != nil {
+				e.Done <- ErrRoomStopped
+			}
+		default:
+			return
+		}
+	}
+}
+
+// touch marks the room as active now
+func (rm *RoomManager) touch() {
+	rm.lastActive.Store(time.Now().UnixNano())
+}
+
+// idleSince reports whether nothing happened in the room since t: no listener, no event, no pending timer
+// and no freeze waiting for its reveal
+func (rm *RoomManager) idleSince(t time.Time) bool {
+	if time.Unix(0, rm.lastActive.Load()).After(t) {
+		return false
+	}
+
+	rm.freezeMu.RLock()
+	frozen := rm.freeze != nil
+	rm.freezeMu.RUnlock()
+	if frozen {
+		return false
+	}
+
+	rm.Mu.RLock()
+	listeners := len(rm.Listerners)
+	rm.Mu.RUnlock()
+	if listeners > 0 {
+		return false
+	}
+
+	rm.timerMu.Lock()
+	defer rm.timerMu.Unlock()
+	return rm.timer == nil
+}
+
+// register adds a manager to the registry, the caller must hold gr.Mu
+func (gr *GlobalRooms) register(rm *RoomManager) {
+	rm.onStop = gr.unregister
+	gr.Rooms[rm.RoomId] = rm
+}
+
+func (gr *GlobalRooms) unregister(rm *RoomManager) {
+	gr.Mu.Lock()
+	defer gr.Mu.Unlock()
+
+	// a newer manager may have taken the room's place already
+	if gr.Rooms[rm.RoomId] == rm {
+		delete(gr.Rooms, rm.RoomId)
+	}
+}
+
+// EvictIdleRooms stops the managers of rooms idle for longer than idleTimeout, they are loaded again on their next use
+func (gr *GlobalRooms) EvictIdleRooms(idleTimeout time.Duration) {
+	ticker := time.NewTicker(max(idleTimeout/2, time.Minute))
+	defer ticker.Stop()
+
+	for range ticker.C {
+		cutoff := time.Now().Add(-idleTimeout)
+
+		gr.Mu.RLock()
+		idle := make([]*RoomManager, 0)
+		for _, rm := range gr.Rooms {
+			if rm.idleSince(cutoff) {
+				idle = append(idle, rm)
+			}
+		}
+		gr.Mu.RUnlock()
+
+		for _, rm := range idle {
+			gr.logger.Info("evicting idle room", "room_id", rm.RoomId)
+			rm.Stop(events.NewSseEvent(events.ROOM_CLOSED, rm.RoomId, events.RoomPayload{RoomID: rm.RoomId}))
+		}
+	}
+}
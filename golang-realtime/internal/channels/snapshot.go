@@ -0,0 +1,146 @@
+package channels
+
+import (
+	"context"
+	"golang-realtime/internal/events"
+	"time"
+)
+
+// kindSnapshotRequested is only known to the room, handlers go through Snapshot
+const kindSnapshotRequested events.Kind = "snapshot_requested"
+
+type snapshotRequested struct {
+	roomID int32
+	done   chan<- snapshotOutcome // buffered
+}
+
+type snapshotOutcome struct {
+	snapshot    events.SnapshotPayload
+	lastEventID int64
+	err         error
+}
+
+func (e snapshotRequested) Kind() events.Kind { return kindSnapshotRequested }
+func (e snapshotRequested) Room() int32       { return e.roomID }
+
+// Snapshot returns the state of the room for a client that just connected, with the id of the last event it includes.
+// It is taken on the room's event loop between two events, the client goes on with the events after that id
+func (rm *RoomManager) Snapshot(ctx context.Context) (events.SnapshotPayload, int64, error) {
+	done := make(chan snapshotOutcome, 1)
+	if err := rm.Publish(snapshotRequested{roomID: rm.RoomId, done: done}); err != nil {
+		return events.SnapshotPayload{}, 0, err
+	}
+
+	select {
+	case outcome := <-done:
+		return outcome.snapshot, outcome.lastEventID, outcome.err
+	case <-rm.done:
+		return events.SnapshotPayload{}, 0, ErrRoomStopped
+	case <-ctx.Done():
+		return events.SnapshotPayload{}, 0, ctx.Err()
+	}
+}
+
+func (rm *RoomManager) processSnapshotRequested(e snapshotRequested) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultQueryTimeoutSecond)
+	defer cancel()
+
+	snapshot, err := rm.snapshot(ctx)
+
+	rm.Mu.RLock()
+	lastEventID := rm.lastEventID
+	rm.Mu.RUnlock()
+
+	e.done <- snapshotOutcome{snapshot: snapshot, lastEventID: lastEventID, err: err}
+	return err
+}
+
+// snapshot reads the state of the room
+func (rm *RoomManager) snapshot(ctx context.Context) (events.SnapshotPayload, error) {
+	room, err := rm.queries.GetRoom(ctx, rm.RoomId)
+	if err != nil {
+		return events.SnapshotPayload{}, err
+	}
+
+	state := events.RoomState{
+		RoomID:                     room.ID,
+		Name:                       room.Name,
+		Description:                room.Description.String,
+		Status:                     room.Status,
+		ScoringMode:                room.ScoringMode,
+		FeedbackLevel:              room.FeedbackLevel,
+		AllowedLanguages:           room.AllowedLanguages,
+		MaxSubmissions:             room.MaxSubmissions,
+		WrongAnswerCooldownSeconds: room.WrongAnswerCooldownSeconds,
+		DurationMinutes:            room.DurationMinutes,
+		FreezeMinutes:              room.FreezeMinutes,
+	}
+	if state.AllowedLanguages == nil {
+		state.AllowedLanguages = []string{}
+	}
+	if room.StartedAt.Valid {
+		state.StartedAt = &room.StartedAt.Time
+	}
+	if room.EndsAt.Valid {
+		state.EndsAt = &room.EndsAt.Time
+		if RoomStatus(room.Status) == StatusRunning {
+			remaining := int64(max(time.Until(room.EndsAt.Time), 0) / time.Second)
+			state.RemainingSeconds = &remaining
+		}
+	}
+
+	questionRows, err := rm.queries.ListRoomQuestions(ctx, rm.RoomId)
+	if err != nil {
+		return events.SnapshotPayload{}, err
+	}
+	questions := make([]events.RoomQuestion, 0, len(questionRows))
+	for _, q := range questionRows {
+		questions = append(questions, events.RoomQuestion{
+			QuestionID: q.QuestionID,
+			Position:   q.Position,
+			Title:      q.Title,
+			Difficulty: q.Difficulty,
+			Points:     q.Points,
+		})
+	}
+
+	leaderboard, err := rm.currentLeaderboard(ctx)
+	if err != nil {
+		return events.SnapshotPayload{}, err
+	}
+
+	players, err := rm.connectedPlayers(ctx)
+	if err != nil {
+		return events.SnapshotPayload{}, err
+	}
+
+	return events.SnapshotPayload{
+		Room:        state,
+		Questions:   questions,
+		Leaderboard: leaderboard,
+		Players:     players,
+	}, nil
+}
+
+// connectedPlayers returns the players of the room with a listener, ordered by place
+func (rm *RoomManager) connectedPlayers(ctx context.Context) ([]events.PlayerPayload, error) {
+	roomPlayers, err := rm.queries.ListPlayersInRoom(ctx, rm.RoomId)
+	if err != nil {
+		return nil, err
+	}
+
+	rm.Mu.RLock()
+	defer rm.Mu.RUnlock()
+
+	players := make([]events.PlayerPayload, 0, len(rm.Listerners))
+	for _, player := range roomPlayers {
+		if rm.connectedLocked(player.ID) {
+			players = append(players, events.PlayerPayload{
+				PlayerID:   player.ID,
+				PlayerName: player.Name,
+			})
+		}
+	}
+
+	return players, nil
+}
@@ -0,0 +1,125 @@
+package channels
+
+import (
+	"context"
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func acceptedResult(playerID, questionID int32) events.SolutionResult {
+	return events.SolutionResult{
+		SolutionSubmitted: events.SolutionSubmitted{
+			PlayerId:      playerID,
+			RoomId:        1,
+			QuestionId:    questionID,
+			Language:      "go",
+			Code:          "package main",
+			SubmittedTime: time.Now(),
+		},
+		Status:        events.Accepted,
+		Message:       "Solution accepted",
+		QuestionScore: 100,
+	}
+}
+
+func TestRecordSolveDuplicateLeavesNoSubmission(t *testing.T) {
+	db := newFakeDB()
+	rm := NewRoomManager(1, store.New(db), nil)
+	ctx := context.Background()
+
+	solved, err := rm.recordSolve(ctx, acceptedResult(7, 3), 100, 0)
+	if err != nil || !solved {
+		t.Fatalf("first accept: solved = %v, err = %v, want a solve", solved, err)
+	}
+
+	solved, err = rm.recordSolve(ctx, acceptedResult(7, 3), 40, 0)
+	if err != nil || solved {
+		t.Fatalf("second accept: solved = %v, err = %v, want no solve", solved, err)
+	}
+
+	if db.submissions != 1 {
+		t.Errorf("kept %d accepted submissions, want 1", db.submissions)
+	}
+	if got := db.solves[solveKey{1, 7, 3}].AwardedPoints; got != 100 {
+		t.Errorf("awarded points = %d, want the 100 of the first accept", got)
+	}
+}
+
+func TestRecordSolveConcurrentAccepts(t *testing.T) {
+	db := newFakeDB()
+	rm := NewRoomManager(1, store.New(db), nil)
+
+	const accepts = 8
+	var wg sync.WaitGroup
+	results := make(chan bool, accepts)
+	for range accepts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			solved, err := rm.recordSolve(context.Background(), acceptedResult(7, 3), 100, 0)
+			if err != nil {
+				t.Errorf("recordSolve: %v", err)
+			}
+			results <- solved
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	var solves int
+	for solved := range results {
+		if solved {
+			solves++
+		}
+	}
+	if solves != 1 {
+		t.Errorf("%d accepts were recorded as solves, want 1", solves)
+	}
+	if len(db.solves) != 1 {
+		t.Errorf("ledger has %d solves, want 1", len(db.solves))
+	}
+	if db.submissions != 1 {
+		t.Errorf("kept %d accepted submissions, want 1", db.submissions)
+	}
+}
+
+func TestAlreadySolvedIsNotScored(t *testing.T) {
+	db := newFakeDB()
+	db.solves[solveKey{1, 7, 3}] = store.RoomSolve{
+		RoomID:        1,
+		PlayerID:      7,
+		QuestionID:    3,
+		SubmissionID:  pgtype.Int4{Int32: 1, Valid: true},
+		AwardedPoints: 100,
+	}
+	rm := NewRoomManager(1, store.New(db), nil)
+	rm.freezeLifted = true // no scoreboard freeze to look up
+
+	listener := NewListener(7, DefaultStreamOptions())
+	defer listener.Close()
+	rm.Listen(listener, 0)
+
+	if err := rm.processSolutionResult(acceptedResult(7, 3)); err != nil {
+		t.Fatalf("processSolutionResult: %v", err)
+	}
+
+	for _, write := range []string{"CreateAcceptedSubmission", "CreateRoomSolve", "UpsertRoomQuestionScore", "RefreshRoomPlayerScore", "UpdateRoomPlayerRanks"} {
+		if db.ran(write) {
+			t.Errorf("%s ran for an already solved question", write)
+		}
+	}
+
+	select {
+	case e := <-listener.Events():
+		if e.EventType != events.QUESTION_ALREADY_SOLVED {
+			t.Errorf("player got %s, want %s", e.EventType, events.QUESTION_ALREADY_SOLVED)
+		}
+	case <-time.After(time.Second):
+		t.Errorf("player got no %s", events.QUESTION_ALREADY_SOLVED)
+	}
+}
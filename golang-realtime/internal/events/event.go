@@ -0,0 +1,54 @@
+package events
+
+// Kind names an Event, the room's event loop finds the handler of an event by its kind
+type Kind string
+
+const (
+	KindSolutionSubmitted  Kind = "solution_submitted"
+	KindSolutionResult     Kind = "solution_result"
+	KindLeaderboardUpdated Kind = "leaderboard_updated"
+	KindPlayerJoined       Kind = "player_joined"
+	KindPlayerLeft         Kind = "player_left"
+	KindPlayerKicked       Kind = "player_kicked"
+	KindRoomDeleted        Kind = "room_deleted"
+	KindCountdownEnded     Kind = "countdown_ended"
+	KindMatchEnded         Kind = "match_ended"
+	KindChatMessage        Kind = "chat_message"
+)
+
+// Event is something that happened in a room
+type Event interface {
+	Kind() Kind
+	// Room is the id of the room the event belongs to
+	Room() int32
+}
+
+func (e SolutionSubmitted) Kind() Kind  { return KindSolutionSubmitted }
+func (e SolutionSubmitted) Room() int32 { return e.RoomId }
+
+func (e SolutionResult) Kind() Kind  { return KindSolutionResult }
+func (e SolutionResult) Room() int32 { return e.SolutionSubmitted.RoomId }
+
+func (e LeaderboardUpdated) Kind() Kind  { return KindLeaderboardUpdated }
+func (e LeaderboardUpdated) Room() int32 { return e.RoomId }
+
+func (e PlayerJoined) Kind() Kind  { return KindPlayerJoined }
+func (e PlayerJoined) Room() int32 { return e.RoomID }
+
+func (e PlayerLeft) Kind() Kind  { return KindPlayerLeft }
+func (e PlayerLeft) Room() int32 { return e.RoomId }
+
+func (e PlayerKicked) Kind() Kind  { return KindPlayerKicked }
+func (e PlayerKicked) Room() int32 { return e.RoomId }
+
+func (e RoomDeleted) Kind() Kind  { return KindRoomDeleted }
+func (e RoomDeleted) Room() int32 { return e.RoomId }
+
+func (e CountdownEnded) Kind() Kind  { return KindCountdownEnded }
+func (e CountdownEnded) Room() int32 { return e.RoomId }
+
+func (e MatchEnded) Kind() Kind  { return KindMatchEnded }
+func (e MatchEnded) Room() int32 { return e.RoomId }
+
+func (e ChatMessage) Kind() Kind  { return KindChatMessage }
+func (e ChatMessage) Room() int32 { return e.RoomId }
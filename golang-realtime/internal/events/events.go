@@ -9,27 +9,61 @@ type EventType string
 const (
 	CORRECT_SOLUTION_SUBMITTED EventType = "CORRECT_SOLUTION_SUBMITTED"
 	WRONG_SOLUTION_SUBMITTED   EventType = "WRONG_SOLUTION_SUBMITTED"
+	PARTIAL_SOLUTION_SUBMITTED EventType = "PARTIAL_SOLUTION_SUBMITTED"
 	SOLUTION_SUBMITTED         EventType = "SOLUTION_SUBMITTED"
+	SAMPLE_RUN_RESULT          EventType = "SAMPLE_RUN_RESULT"
+	QUESTION_ALREADY_SOLVED    EventType = "QUESTION_ALREADY_SOLVED"
+	SCOREBOARD_FROZEN          EventType = "SCOREBOARD_FROZEN"
+	SCOREBOARD_UNFROZEN        EventType = "SCOREBOARD_UNFROZEN"
+	RESULT_REVEALED            EventType = "RESULT_REVEALED"
+	ROOM_COUNTDOWN             EventType = "ROOM_COUNTDOWN"
+	ROOM_STARTED               EventType = "ROOM_STARTED"
+	ROOM_FINISHED              EventType = "ROOM_FINISHED"
+	ROOM_SETTINGS_UPDATED      EventType = "ROOM_SETTINGS_UPDATED"
+	PLAYER_KICKED              EventType = "PLAYER_KICKED"
 	PLAYER_JOINED              EventType = "PLAYER_JOINED"
 	PLAYER_LEFT                EventType = "PLAYER_LEFT"
 	ROOM_DELETED               EventType = "ROOM_DELETED"
+	ROOM_CLOSED                EventType = "ROOM_CLOSED"
 	COMPILATION_TEST           EventType = "COMPILATION_TEST"
+	LEADERBOARD_UPDATED        EventType = "LEADERBOARD_UPDATED"
+	ROOM_SNAPSHOT              EventType = "ROOM_SNAPSHOT"
+	STREAM_CLOSED              EventType = "STREAM_CLOSED"
+	CHAT_MESSAGE               EventType = "CHAT_MESSAGE"
 )
 
-// Event wrapper for the listener
+// SseEvent is the envelope of every event sent to the clients, Data is one of the payloads of payloads.go.
+// The wire format is described by schema.json, served at GET /events/schema
 type SseEvent struct {
-	EventType EventType
-	Data      any
+	ID        int64     `json:"id"` // set when the room sends the event, grows with every event of the room
+	Version   int       `json:"version"`
+	EventType EventType `json:"type"`
+	RoomID    int32     `json:"room_id"`
+	Timestamp time.Time `json:"timestamp"`
+	Data      any       `json:"data"`
+}
+
+// NewSseEvent wraps the payload of an event of the room in the current schema version
+func NewSseEvent(eventType EventType, roomID int32, data any) SseEvent {
+	return SseEvent{
+		Version:   SchemaVersion,
+		EventType: eventType,
+		RoomID:    roomID,
+		Timestamp: time.Now().UTC(),
+		Data:      data,
+	}
 }
 
 type JudgeStatus string
 
 const (
 	Accepted            JudgeStatus = "Accepted"
+	PartiallyAccepted   JudgeStatus = "Partially Accepted"
 	WrongAnswer         JudgeStatus = "Wrong Answer"
 	RuntimeError        JudgeStatus = "Runtime Error"
 	CompilationError    JudgeStatus = "Compilation Error"
-	TimeLimitExceeded   JudgeStatus = "Time Limit Exceeded"   // For the future
+	TimeLimitExceeded   JudgeStatus = "Time Limit Exceeded"
+	JudgementFailed     JudgeStatus = "Judgement Failed"
 	MemoryLimitExceeded JudgeStatus = "Memory Limit Exceeded" // For the future
 )
 
@@ -40,21 +74,63 @@ type SolutionSubmitted struct {
 	Code          string
 	Language      string
 	SubmittedTime time.Time
+	SampleOnly    bool // judge against the sample tests only, nothing is scored
 }
 
 type SolutionResult struct {
 	SolutionSubmitted SolutionSubmitted
 	Status            JudgeStatus
 	Message           string
+	Subtasks          []SubtaskResult // empty if the question has no subtasks
+	QuestionScore     int32
+	Difficulty        int32
+	LanguageID        int32
+}
+
+type SubtaskResult struct {
+	Name   string
+	Weight int32
+	Passed bool
 }
 
+// ScoreRatio is the share of the question's points earned by the solution.
+// Full credit only goes to accepted solutions, partial ones earn the weight of the subtasks they passed
+func (r SolutionResult) ScoreRatio() float64 {
+	switch r.Status {
+	case Accepted:
+		return 1
+	case PartiallyAccepted:
+		return SubtaskRatio(r.Subtasks)
+	}
+	return 0
+}
+
+// SubtaskRatio is the share of the subtask weights passed
+func SubtaskRatio(subtasks []SubtaskResult) float64 {
+	var passed, total int32
+	for _, st := range subtasks {
+		total += st.Weight
+		if st.Passed {
+			passed += st.Weight
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+
+	return float64(passed) / float64(total)
+}
+
+// LeaderboardUpdated tells observers the standings of the room were recalculated
 type LeaderboardUpdated struct {
-	RoomId int
+	RoomId int32
 }
 
 type PlayerJoined struct {
 	PlayerID int32
 	RoomID   int32
+	Done     chan<- error // optional, gets the outcome of the join, must be buffered
 }
 
 type PlayerLeft struct {
@@ -62,6 +138,30 @@ type PlayerLeft struct {
 	RoomId   int32
 }
 
+// PlayerKicked is a player removed from the room by one of its hosts
+type PlayerKicked struct {
+	PlayerId int32
+	RoomId   int32
+}
+
 type RoomDeleted struct {
 	RoomId int32
 }
+
+// CountdownEnded is sent by the room's own timer once the countdown elapsed
+type CountdownEnded struct {
+	RoomId int32
+}
+
+// MatchEnded is sent by the room's own timer once the match duration is over
+type MatchEnded struct {
+	RoomId int32
+}
+
+// ChatMessage is a message a player sent to the room
+type ChatMessage struct {
+	PlayerId int32
+	RoomId   int32
+	Message  string
+	SentAt   time.Time
+}
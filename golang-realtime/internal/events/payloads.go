@@ -0,0 +1,140 @@
+package events
+
+import (
+	_ "embed"
+	"time"
+)
+
+// SchemaVersion is bumped whenever a field of a payload is renamed, retyped or removed.
+// Adding a field is not a breaking change and keeps the version
+const SchemaVersion = 1
+
+// Schema is the JSON schema of SseEvent and its payloads
+//
+//go:embed schema.json
+var Schema []byte
+
+// RoomPayload is the data of the events about the room as a whole:
+// ROOM_FINISHED, ROOM_SETTINGS_UPDATED, ROOM_DELETED, ROOM_CLOSED, SCOREBOARD_FROZEN and SCOREBOARD_UNFROZEN
+type RoomPayload struct {
+	RoomID int32 `json:"room_id"`
+}
+
+// CountdownPayload is the data of ROOM_COUNTDOWN
+type CountdownPayload struct {
+	RoomID   int32     `json:"room_id"`
+	StartsAt time.Time `json:"starts_at"`
+}
+
+// MatchStartedPayload is the data of ROOM_STARTED, EndsAt is left out for rooms without a duration
+type MatchStartedPayload struct {
+	RoomID    int32      `json:"room_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndsAt    *time.Time `json:"ends_at,omitempty"`
+}
+
+// PlayerPayload is the data of PLAYER_JOINED, PLAYER_LEFT and PLAYER_KICKED
+type PlayerPayload struct {
+	PlayerID   int32  `json:"player_id"`
+	PlayerName string `json:"player_name"`
+}
+
+// VerdictPayload is the data of the judged submissions: SAMPLE_RUN_RESULT, WRONG_SOLUTION_SUBMITTED,
+// PARTIAL_SOLUTION_SUBMITTED, CORRECT_SOLUTION_SUBMITTED and QUESTION_ALREADY_SOLVED.
+// Points is what the question is now worth to the player, ScoreDelta how much their room score changed with it
+type VerdictPayload struct {
+	PlayerID    int32       `json:"player_id"`
+	PlayerName  string      `json:"player_name"`
+	QuestionID  int32       `json:"question_id"`
+	Language    string      `json:"language"`
+	Status      JudgeStatus `json:"status"`
+	Message     string      `json:"message,omitempty"`
+	Points      int32       `json:"points"`
+	ScoreDelta  int32       `json:"score_delta"`
+	Score       int32       `json:"score"`
+	SubmittedAt time.Time   `json:"submitted_at"`
+}
+
+// LeaderboardEntry is the standing of one player, Delta is how much their score changed since the previous update
+type LeaderboardEntry struct {
+	PlayerID   int32  `json:"player_id"`
+	PlayerName string `json:"player_name"`
+	Score      int32  `json:"score"`
+	Place      int32  `json:"place"`
+	Penalty    int32  `json:"penalty"`
+	Delta      int32  `json:"delta"`
+}
+
+// LeaderboardPayload is the data of LEADERBOARD_UPDATED. Entries always holds the full ranking,
+// Changed and Removed are the diff against the update of the previous revision. Revisions follow each other,
+// a client that missed one should take Entries as is rather than apply the diff
+type LeaderboardPayload struct {
+	Revision int64              `json:"revision"`
+	Frozen   bool               `json:"frozen"`
+	Entries  []LeaderboardEntry `json:"entries"`
+	Changed  []LeaderboardEntry `json:"changed"`
+	Removed  []int32            `json:"removed"`
+}
+
+// RevealedResultPayload is the data of RESULT_REVEALED, a result that was kept back during the freeze.
+// Score, Penalty and Place are the player's standing once it counts, Remaining how many results are still kept back
+type RevealedResultPayload struct {
+	PlayerID   int32       `json:"player_id"`
+	PlayerName string      `json:"player_name"`
+	QuestionID int32       `json:"question_id"`
+	Status     JudgeStatus `json:"status"`
+	Score      int32       `json:"score"`
+	Penalty    int32       `json:"penalty"`
+	Place      int32       `json:"place"`
+	Remaining  int         `json:"remaining"`
+}
+
+// SnapshotPayload is the data of ROOM_SNAPSHOT, the first event of a connection: the state of the room
+// as of the event id it is sent with. Leaderboard.Revision is the last LEADERBOARD_UPDATED it includes
+type SnapshotPayload struct {
+	Room        RoomState          `json:"room"`
+	Questions   []RoomQuestion     `json:"questions"` // empty if the whole catalog is open
+	Leaderboard LeaderboardPayload `json:"leaderboard"`
+	Players     []PlayerPayload    `json:"players"` // players connected to the room
+}
+
+// RoomState is the settings and match state of a room. RemainingSeconds is only set while a match with a duration runs
+type RoomState struct {
+	RoomID                     int32      `json:"room_id"`
+	Name                       string     `json:"name"`
+	Description                string     `json:"description"`
+	Status                     string     `json:"status"`
+	ScoringMode                string     `json:"scoring_mode"`
+	FeedbackLevel              string     `json:"feedback_level"`
+	AllowedLanguages           []string   `json:"allowed_languages"`
+	MaxSubmissions             int32      `json:"max_submissions"`
+	WrongAnswerCooldownSeconds int32      `json:"wrong_answer_cooldown_seconds"`
+	DurationMinutes            int32      `json:"duration_minutes"`
+	FreezeMinutes              int32      `json:"freeze_minutes"`
+	StartedAt                  *time.Time `json:"started_at,omitempty"`
+	EndsAt                     *time.Time `json:"ends_at,omitempty"`
+	RemainingSeconds           *int64     `json:"remaining_seconds,omitempty"`
+}
+
+// RoomQuestion is a question of the room's question set
+type RoomQuestion struct {
+	QuestionID int32  `json:"question_id"`
+	Position   int32  `json:"position"`
+	Title      string `json:"title"`
+	Difficulty int32  `json:"difficulty"`
+	Points     int32  `json:"points"`
+}
+
+// StreamClosedPayload is the data of STREAM_CLOSED, the last event of a connection the server gave up on.
+// It has no id, the client reconnects with the id of the last event it got
+type StreamClosedPayload struct {
+	Reason string `json:"reason"`
+}
+
+// ChatPayload is the data of CHAT_MESSAGE
+type ChatPayload struct {
+	PlayerID   int32     `json:"player_id"`
+	PlayerName string    `json:"player_name"`
+	Message    string    `json:"message"`
+	SentAt     time.Time `json:"sent_at"`
+}
@@ -283,23 +283,42 @@ func (d *DockerContainerManager) balanceWorker() error {
 
 // GetAvailableContainer finds an Idle Container
 func (d *DockerContainerManager) GetAvailableContainer() (string, error) {
+	ids, err := d.GetAvailableContainers(1)
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// GetAvailableContainers finds n Idle Containers and marks them Busy at once,
+// so two jobs never end up holding part of what they need each. It returns nil if they can't all be found
+func (d *DockerContainerManager) GetAvailableContainers(n int) ([]string, error) {
 	for range maxRetries {
 		// Lock every trial
 		d.mu.Lock()
+		ids := make([]string, 0, n)
 		for id, info := range d.containers {
+			if len(ids) == n {
+				break
+			}
 			if info.State == StateIdle {
-				info.State = StateBusy
-				d.mu.Unlock()
-				d.logger.Info("Container is assigned to job",
-					"container_id", id)
-				return id, nil
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == n {
+			for _, id := range ids {
+				d.containers[id].State = StateBusy
 			}
+			d.mu.Unlock()
+			d.logger.Info("Containers are assigned to job",
+				"container_ids", ids)
+			return ids, nil
 		}
 		d.mu.Unlock()
 		time.Sleep(time.Duration(retryDelayMS) * time.Millisecond)
 	}
 
-	return "", nil
+	return nil, nil
 }
 
 // ShutDown cleans up all containers
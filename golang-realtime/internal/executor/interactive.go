@@ -0,0 +1,257 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"golang-realtime/internal/store"
+)
+
+// Interactor exit codes, following the testlib convention
+const (
+	InteractorAccepted          = 0
+	InteractorWrongAnswer       = 1
+	InteractorPresentationError = 2
+
+	// interactorInputPath is where the test input is staged inside the interactor's container,
+	// the interactor finds it through the INPUT_FILE environment variable
+	interactorInputPath = "/app/temp/interactor.in"
+)
+
+var (
+	ErrNoContainerAvailable error = errors.New("No container available")
+)
+
+// Interactor is the judge-side program of an interactive problem.
+// It talks to the user's program over stdin/stdout and decides the verdict with its exit code
+type Interactor struct {
+	Language  store.Language
+	Code      string
+	TimeLimit time.Duration
+}
+
+// InteractionResult holds what happened on both sides of an interactive run
+type InteractionResult struct {
+	ProgramExitCode    int
+	ProgramTimedOut    bool
+	ProgramStderr      string
+	InteractorExitCode int
+	InteractorTimedOut bool
+	InteractorMessage  string
+}
+
+// ExecuteInteractiveJob submits an interactive job for execution,
+// the user's program and the interactor are run in two containers with their stdin/stdout cross-wired
+func (w *WorkerPool) ExecuteInteractiveJob(lang store.Language, code string, input *string, timeLimit time.Duration, interactor *Interactor) Result {
+	w.logger.Info("Submitting interactive job...",
+		"language", lang,
+		"interactor_language", interactor.Language)
+
+	result := make(chan Result, 1)
+	job := Job{
+		Language:   lang,
+		Code:       code,
+		Input:      input,
+		TimeLimit:  timeLimit,
+		Interactor: interactor,
+		Result:     result,
+	}
+
+	select {
+	case w.jobs <- job:
+		return <-result
+	default:
+		w.logger.Warn("Job queue is full, rejecting interactive job...",
+			"language", lang,
+			"maxJobCount", w.cm.maxWorkers)
+		return Result{Error: ErrJobQueueFull}
+	}
+}
+
+// executeInteractiveJob handle the execution of a *single* interactive job
+func (w *WorkerPool) executeInteractiveJob(workerID int, job Job) {
+	// both sides are reserved together, holding one while waiting for the other could starve the pool
+	containerIDs, err := w.cm.GetAvailableContainers(2)
+	if err == nil && len(containerIDs) < 2 {
+		err = ErrNoContainerAvailable
+	}
+	if err != nil {
+		w.logger.Error("Failed to get available Containers for program and interactor",
+			"err", err)
+		job.Result <- Result{Error: err}
+		return
+	}
+	programContainerID, interactorContainerID := containerIDs[0], containerIDs[1]
+	defer w.cm.SetContainerState(programContainerID, StateIdle)
+	defer w.cm.SetContainerState(interactorContainerID, StateIdle)
+
+	start := time.Now()
+	interaction, err := w.executeInteraction(job, programContainerID, interactorContainerID)
+	duration := time.Since(start)
+
+	if err != nil {
+		w.logger.Error("Worker interactive job failed",
+			"worker_id", workerID,
+			"program_container_id", programContainerID,
+			"interactor_container_id", interactorContainerID,
+			"duration", duration.Milliseconds(),
+			"err", err)
+	} else {
+		w.logger.Info("Worker interactive job completed",
+			"worker_id", workerID,
+			"program_container_id", programContainerID,
+			"interactor_container_id", interactorContainerID,
+			"duration", duration.Milliseconds(),
+			"interaction", interaction)
+	}
+
+	job.Result <- Result{
+		Output:        interaction.InteractorMessage,
+		Sucess:        err == nil && interaction.InteractorExitCode == InteractorAccepted,
+		Error:         err,
+		ExecutionTime: fmt.Sprintf("%dms", duration.Milliseconds()),
+		Interaction:   &interaction,
+	}
+}
+
+// executeInteraction runs the user's program and the interactor side by side,
+// the program's stdout is the interactor's stdin and vice versa.
+// Each side is killed once its own time limit is exceeded
+func (w *WorkerPool) executeInteraction(job Job, programContainerID, interactorContainerID string) (InteractionResult, error) {
+	var result InteractionResult
+
+	if job.Input != nil {
+		if err := w.stageInteractorInput(interactorContainerID, *job.Input); err != nil {
+			return result, err
+		}
+	}
+
+	// builds are not part of either time limit
+	buildCtx, cancelBuild := context.WithTimeout(context.Background(), QueryTimeOutSecond)
+	defer cancelBuild()
+	programRunCmd, _, err := w.prepareRun(buildCtx, programContainerID, job.Language, job.Code)
+	if err != nil {
+		return result, err
+	}
+	interactorRunCmd, _, err := w.prepareRun(buildCtx, interactorContainerID, job.Interactor.Language, job.Interactor.Code)
+	if err != nil {
+		// the interactor is on the judge, its build failing is not the player's compilation error
+		return result, fmt.Errorf("interactor: %v", err)
+	}
+
+	programCtx, cancelProgram := context.WithTimeout(context.Background(), job.TimeLimit)
+	defer cancelProgram()
+	interactorCtx, cancelInteractor := context.WithTimeout(context.Background(), job.Interactor.TimeLimit)
+	defer cancelInteractor()
+
+	programCmd := exec.CommandContext(programCtx, "docker", "exec", "-i", programContainerID,
+		"sh", "-c", programRunCmd)
+	interactorCmd := exec.CommandContext(interactorCtx, "docker", "exec", "-i",
+		"-e", "INPUT_FILE="+interactorInputPath, interactorContainerID,
+		"sh", "-c", interactorRunCmd)
+
+	// program -> interactor
+	toInteractor, fromProgram, err := os.Pipe()
+	if err != nil {
+		return result, err
+	}
+	// interactor -> program
+	toProgram, fromInteractor, err := os.Pipe()
+	if err != nil {
+		toInteractor.Close()
+		fromProgram.Close()
+		return result, err
+	}
+
+	var programStderr, interactorStderr bytes.Buffer
+	programCmd.Stdin = toProgram
+	programCmd.Stdout = fromProgram
+	programCmd.Stderr = &programStderr
+	interactorCmd.Stdin = toInteractor
+	interactorCmd.Stdout = fromInteractor
+	interactorCmd.Stderr = &interactorStderr
+
+	if err := interactorCmd.Start(); err != nil {
+		closeAll(toInteractor, fromProgram, toProgram, fromInteractor)
+		return result, err
+	}
+	if err := programCmd.Start(); err != nil {
+		closeAll(toInteractor, fromProgram, toProgram, fromInteractor)
+		interactorCmd.Wait()
+		return result, err
+	}
+
+	// The children hold their own copies of the pipe ends,
+	// closing ours lets each side see EOF as soon as the other one exits
+	closeAll(toInteractor, fromProgram, toProgram, fromInteractor)
+
+	// Each side's deadline is checked as soon as it exits, the other side may keep running
+	// long enough for it to pass without it having mattered
+	var wg sync.WaitGroup
+	var programErr, interactorErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		programErr = programCmd.Wait()
+		result.ProgramTimedOut = errors.Is(programCtx.Err(), context.DeadlineExceeded)
+	}()
+	go func() {
+		defer wg.Done()
+		interactorErr = interactorCmd.Wait()
+		result.InteractorTimedOut = errors.Is(interactorCtx.Err(), context.DeadlineExceeded)
+	}()
+	wg.Wait()
+
+	result.ProgramExitCode = exitCode(programCmd, programErr)
+	result.ProgramStderr = programStderr.String()
+	result.InteractorExitCode = exitCode(interactorCmd, interactorErr)
+	result.InteractorMessage = strings.TrimSpace(interactorStderr.String())
+
+	return result, nil
+}
+
+// stageInteractorInput writes the test input into the interactor's container
+func (w *WorkerPool) stageInteractorInput(containerID, input string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeOutSecond)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", "exec", "-i", containerID,
+		"sh", "-c", fmt.Sprintf("cat > %s", interactorInputPath))
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		w.logger.Error("Failed to stage interactor input",
+			"container_id", containerID,
+			"err", err,
+			"stderr", stderr.String())
+		return err
+	}
+
+	return nil
+}
+
+// exitCode returns the exit code of a finished command, -1 if it was killed or never started
+func exitCode(cmd *exec.Cmd, err error) int {
+	if cmd.ProcessState != nil {
+		return cmd.ProcessState.ExitCode()
+	}
+	if err != nil {
+		return -1
+	}
+	return 0
+}
+
+func closeAll(files ...*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
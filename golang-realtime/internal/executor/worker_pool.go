@@ -3,6 +3,7 @@ package executor
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"golang-realtime/internal/store"
 	"log/slog"
@@ -15,6 +16,15 @@ import (
 const (
 	QueryTimeOutSecond   = 30 * time.Second
 	CodeRunTimeOutSecond = 10 * time.Second
+
+	// containersPerWorker lets every worker run an interactive job, the program and the interactor
+	// each take a container, so no worker ever waits on a container another one holds
+	containersPerWorker = 2
+)
+
+var (
+	ErrJobQueueFull      error = errors.New("Job queue is full")
+	ErrCompilationFailed error = errors.New("Compilation failed")
 )
 
 type Job struct {
@@ -22,6 +32,11 @@ type Job struct {
 	Code     string
 	Input    *string
 	Result   chan Result
+	ctx      context.Context // custom input runs only, a run given up on is not started
+
+	// Interactive jobs only
+	TimeLimit  time.Duration
+	Interactor *Interactor
 }
 
 type Result struct {
@@ -29,6 +44,10 @@ type Result struct {
 	Sucess        bool
 	Error         error
 	ExecutionTime string
+	Stdout        string
+	Stderr        string
+	ExitCode      int
+	Interaction   *InteractionResult // nil unless the job is interactive
 }
 
 type WorkerPool struct {
@@ -36,19 +55,20 @@ type WorkerPool struct {
 	queries      *store.Queries
 	logger       *slog.Logger
 	jobs         chan Job
+	runJobs      chan Job // custom input runs, picked only when no scored job is waiting
 	wg           sync.WaitGroup
 	shutdownChan chan any
 }
 
 type WorkerPoolOptions struct {
-	MaxWorkers       int
+	MaxWorkers       int // jobs run at once, the pool starts containersPerWorker containers for each
 	MemoryLimitBytes int64
 	MaxJobCount      int
 	CpuNanoLimit     int64
 }
 
 func NewWorkerPool(logger *slog.Logger, queries *store.Queries, opts *WorkerPoolOptions) (*WorkerPool, error) {
-	cm, err := NewDockerContainerManager(opts.MaxWorkers, opts.MemoryLimitBytes, opts.CpuNanoLimit)
+	cm, err := NewDockerContainerManager(opts.MaxWorkers*containersPerWorker, opts.MemoryLimitBytes, opts.CpuNanoLimit)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +83,7 @@ func NewWorkerPool(logger *slog.Logger, queries *store.Queries, opts *WorkerPool
 		queries:      queries,
 		logger:       logger,
 		jobs:         make(chan Job, opts.MaxJobCount),
+		runJobs:      make(chan Job, opts.MaxJobCount),
 		shutdownChan: make(chan any),
 	}
 
@@ -72,7 +93,8 @@ func NewWorkerPool(logger *slog.Logger, queries *store.Queries, opts *WorkerPool
 	}
 
 	w.logger.Info("Initialized worker pool with max workers",
-		"max_worker", w.cm.maxWorkers)
+		"max_worker", opts.MaxWorkers,
+		"containers", w.cm.maxWorkers)
 
 	return w, err
 }
@@ -82,6 +104,7 @@ func (w *WorkerPool) worker(id int) {
 	w.logger.Info("Worker started", "id", id)
 
 	for {
+		// scored jobs always go first
 		select {
 		case j, ok := <-w.jobs:
 			if !ok {
@@ -89,7 +112,27 @@ func (w *WorkerPool) worker(id int) {
 					"worker_id", id)
 				return
 			}
-			w.executeJob(id, j)
+			w.handleJob(id, j)
+			continue
+		default:
+		}
+
+		select {
+		case j, ok := <-w.jobs:
+			if !ok {
+				w.logger.Info("Worker shutting down due to channel closed",
+					"worker_id", id)
+				return
+			}
+			w.handleJob(id, j)
+
+		case j, ok := <-w.runJobs:
+			if !ok {
+				w.logger.Info("Worker shutting down due to channel closed",
+					"worker_id", id)
+				return
+			}
+			w.handleJob(id, j)
 
 		case <-w.shutdownChan:
 			w.logger.Info("Worker received shutdown signal", "worker_id", id)
@@ -98,6 +141,18 @@ func (w *WorkerPool) worker(id int) {
 	}
 }
 
+func (w *WorkerPool) handleJob(workerID int, j Job) {
+	if j.ctx != nil && j.ctx.Err() != nil {
+		j.Result <- Result{Error: j.ctx.Err()}
+		return
+	}
+	if j.Interactor != nil {
+		w.executeInteractiveJob(workerID, j)
+		return
+	}
+	w.executeJob(workerID, j)
+}
+
 // ExecuteJob submits the job for execution
 // input as a pointer so we could either set it or make it null
 func (w *WorkerPool) ExecuteJob(lang store.Language, code string, input *string) Result {
@@ -112,7 +167,30 @@ func (w *WorkerPool) ExecuteJob(lang store.Language, code string, input *string)
 		w.logger.Warn("Job queue is full, rejecting job...",
 			"language", lang,
 			"maxJobCount", w.cm.maxWorkers)
-		return Result{}
+		return Result{Error: ErrJobQueueFull}
+	}
+}
+
+// ExecuteRunJob submits a custom input run, it is queued with a lower priority than scored jobs.
+// Once ctx is done the run is no longer waited for, nor started if it is still queued
+func (w *WorkerPool) ExecuteRunJob(ctx context.Context, lang store.Language, code string, input *string) Result {
+	w.logger.Info("Submitting run job...",
+		"language", lang)
+
+	result := make(chan Result, 1)
+	select {
+	case w.runJobs <- Job{Language: lang, Code: code, Input: input, Result: result, ctx: ctx}:
+		select {
+		case r := <-result:
+			return r
+		case <-ctx.Done():
+			return Result{Error: ctx.Err()}
+		}
+	default:
+		w.logger.Warn("Run queue is full, rejecting job...",
+			"language", lang,
+			"maxJobCount", w.cm.maxWorkers)
+		return Result{Error: ErrJobQueueFull}
 	}
 }
 
@@ -121,22 +199,41 @@ func (w *WorkerPool) executeJob(workerID int, job Job) error {
 	w.logger.Info("Job has been picked",
 		"worker_id", workerID,
 		"job", job)
+	// the submitter waits on the result channel, every way out must send on it
 	containerID, err := w.cm.GetAvailableContainer()
+	if err == nil && containerID == "" {
+		err = ErrNoContainerAvailable
+	}
 	if err != nil {
 		w.logger.Error("Failed to get available Container",
 			"err", err)
+		job.Result <- Result{Error: err}
 		return err
 	}
 
 	err = w.cm.SetContainerState(containerID, StateBusy)
 	if err != nil {
+		job.Result <- Result{Error: err}
 		return err
 	}
 
 	start := time.Now()
-	output, success, err := w.executeCode(job.Language, containerID, job.Code, job.Input)
+	stdout, stderr, exitCode, err := w.executeCode(job.Language, containerID, job.Code, job.Input)
 	duration := time.Since(start)
 
+	output := stdout
+	if err != nil {
+		output = stderr
+	}
+
+	// a failing user program must not keep the container busy,
+	// custom input runs exit with a non-zero code all the time
+	if stateErr := w.cm.SetContainerState(containerID, StateIdle); stateErr != nil {
+		w.logger.Error("Failed to release Container",
+			"container_id", containerID,
+			"err", stateErr)
+	}
+
 	if err != nil {
 		w.logger.Error("Worker job failed",
 			"worker_id", workerID,
@@ -145,7 +242,6 @@ func (w *WorkerPool) executeJob(workerID int, job Job) error {
 			"lang", job.Language,
 			"err", err)
 	} else {
-		err = w.cm.SetContainerState(containerID, StateIdle)
 		w.logger.Info("Worker job completed",
 			"worker_id", workerID,
 			"container_id", containerID,
@@ -156,22 +252,29 @@ func (w *WorkerPool) executeJob(workerID int, job Job) error {
 	// send result to result channel
 	job.Result <- Result{
 		Output:        output,
-		Sucess:        success,
+		Sucess:        err == nil,
 		Error:         err,
 		ExecutionTime: fmt.Sprintf("%dms", duration.Milliseconds()),
+		Stdout:        stdout,
+		Stderr:        stderr,
+		ExitCode:      exitCode,
 	}
 
 	return nil
 }
 
 // executeCode run the code in a specific Container
-func (w *WorkerPool) executeCode(lang store.Language, containerID, code string, input *string) (string, bool, error) {
+func (w *WorkerPool) executeCode(lang store.Language, containerID, code string, input *string) (string, string, int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), QueryTimeOutSecond)
 	defer cancel()
 
+	runCmd, compileOutput, err := w.prepareRun(ctx, containerID, lang, code)
+	if err != nil {
+		return "", compileOutput, -1, err
+	}
+
 	// TODO: add input
 	var stdout, stderr bytes.Buffer
-	runCmd := generateRunCmd(lang.RunCmd.String, code)
 
 	// -i for interactive
 	cmd := exec.CommandContext(ctx, "docker", "exec", "-i", containerID, "sh", "-c", runCmd)
@@ -186,8 +289,12 @@ func (w *WorkerPool) executeCode(lang store.Language, containerID, code string,
 	cmd.Stderr = &stderr
 
 	start := time.Now()
-	err := cmd.Run()
+	err = cmd.Run()
 	duration := time.Since(start)
+	if ctx.Err() != nil {
+		// killed at the deadline, not failed on its own
+		err = fmt.Errorf("%w: %w", ctx.Err(), err)
+	}
 	if err != nil {
 		w.logger.Error("Failed to execute code",
 			"container_id", containerID,
@@ -195,14 +302,44 @@ func (w *WorkerPool) executeCode(lang store.Language, containerID, code string,
 			"err", err,
 			"stdout", stdout.String(),
 			"stderr", stderr.String())
-		return stderr.String(), false, err
+		return stdout.String(), stderr.String(), exitCode(cmd, err), err
 	}
 
 	w.logger.Info("Code Execution Completed",
 		"container_id", containerID,
 		"duration", duration)
 
-	return stdout.String(), true, nil
+	return stdout.String(), stderr.String(), 0, nil
+}
+
+// prepareRun builds the code when the language has a compile command and returns the command that runs it,
+// the compiler output comes back with ErrCompilationFailed when the build fails
+func (w *WorkerPool) prepareRun(ctx context.Context, containerID string, lang store.Language, code string) (string, string, error) {
+	if !lang.CompileCmd.Valid || lang.CompileCmd.String == "" {
+		return generateRunCmd(lang.RunCmd.String, code), "", nil
+	}
+
+	var output bytes.Buffer
+	cmd := exec.CommandContext(ctx, "docker", "exec", "-i", containerID,
+		"sh", "-c", generateRunCmd(lang.CompileCmd.String, code))
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		w.logger.Warn("Failed to compile code",
+			"container_id", containerID,
+			"err", err,
+			"output", output.String())
+
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && ctx.Err() == nil {
+			return "", output.String(), fmt.Errorf("%w: %w", ErrCompilationFailed, err)
+		}
+		return "", output.String(), err
+	}
+
+	// the build stays in the container, the run command starts it
+	return lang.RunCmd.String, "", nil
 }
 
 // generateCodeRunCmd will generate a run command for the code
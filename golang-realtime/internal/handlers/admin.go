@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"golang-realtime/internal/channels"
+	"golang-realtime/pkg/common/response"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	adminTokenHeader = "X-Admin-Token"
+)
+
+// isAdmin reports whether the request carries the admin token
+func (hr *HandlerRepo) isAdmin(r *http.Request) bool {
+	if hr.adminToken == "" {
+		return false
+	}
+	token := r.Header.Get(adminTokenHeader)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(hr.adminToken)) == 1
+}
+
+// RevealNextResultHandler reveals the oldest result kept back by the scoreboard freeze, one per call
+func (hr *HandlerRepo) RevealNextResultHandler(w http.ResponseWriter, r *http.Request) {
+	if !hr.isAdmin(r) {
+		response.JSON(w, http.StatusUnauthorized, nil, true, "admin token required")
+		return
+	}
+
+	roomId, err := strconv.ParseInt(chi.URLParam(r, "roomId"), 10, 32)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invalid room ID")
+		return
+	}
+
+	rm, ok := hr.roomManager(w, r, int32(roomId))
+	if !ok {
+		return
+	}
+
+	revealed, err := rm.RevealNext(r.Context())
+	switch {
+	case errors.Is(err, channels.ErrNotFrozen),
+		errors.Is(err, channels.ErrRoomNotEnded),
+		errors.Is(err, channels.ErrNothingToReveal):
+		response.JSON(w, http.StatusConflict, nil, true, err.Error())
+		return
+	case err != nil:
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, revealed, false, "result revealed")
+}
@@ -1,12 +1,30 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"expvar"
 	"fmt"
+	"golang-realtime/internal/channels"
 	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+var (
+	errNotInRoom = errors.New("join the room before listening to its events")
+)
+
+// eventWriter sends the events of a room to one client, over SSE or a WebSocket
+type eventWriter interface {
+	writeEvent(events.SseEvent) error
+	// writeHeartbeat keeps a quiet connection from being closed by proxies
+	writeHeartbeat() error
+}
+
 func (hr *HandlerRepo) EventHandler(w http.ResponseWriter, r *http.Request) {
 	playerId, roomId, err := getRequestPlayerIdAndRoomId(r, hr.logger)
 	if err != nil {
@@ -14,74 +32,224 @@ func (hr *HandlerRepo) EventHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := hr.canListen(r.Context(), roomId, playerId); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
+
 	// Set http headers required for SSE
 	w.Header().Set("Content-Type", "text/event-stream")
 	w.Header().Set("Cache-Control", "no-cache")
 	w.Header().Set("Connection", "keep-alive")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control")
+	w.Header().Set("Access-Control-Allow-Headers", "Cache-Control, Last-Event-ID")
 
 	// Get the room manager for the requested room.
-	roomManager := hr.gr.GetRoomById(roomId)
-	if roomManager == nil {
+	roomManager, err := hr.gr.GetOrLoadRoom(r.Context(), roomId)
+	if err != nil {
 		http.Error(w, "room not found or not active", http.StatusNotFound)
 		return
 	}
 
-	// listen for incoming SseEvents
-	listen := make(chan events.SseEvent) // Add buffer to prevent blocking
+	// a reconnecting browser sends the id of the last event it got, other clients may pass it in the query
+	lastEventID, err := getLastEventId(r)
+	if err != nil {
+		http.Error(w, "invalid last event id", http.StatusBadRequest)
+		return
+	}
+
+	hr.streamRoom(r.Context(), roomManager, playerId, lastEventID, sseWriter{w: w})
+}
 
-	// Properly lock when modifying listeners
-	roomManager.Mu.Lock()
-	if roomManager.Listerners == nil {
-		roomManager.Listerners = make(map[int32]chan<- events.SseEvent)
+// canListen checks that the player joined the room through the join endpoint and was not kicked since
+func (hr *HandlerRepo) canListen(ctx context.Context, roomId, playerId int32) error {
+	roomPlayer, err := hr.queries.GetRoomPlayer(ctx, store.GetRoomPlayerParams{
+		RoomID:   roomId,
+		PlayerID: playerId,
+	})
+	if err != nil {
+		return errNotInRoom
+	}
+	if roomPlayer.State.String == channels.PlayerLeft {
+		return channels.ErrPlayerKicked
 	}
-	roomManager.Listerners[playerId] = listen
-	roomManager.Mu.Unlock()
+	return nil
+}
+
+// streamRoom sends the events of the room to the client until ctx ends, the room stops or the client falls too far behind.
+// The client starts from a snapshot of the room, or from the events after lastEventID when it reconnects
+func (hr *HandlerRepo) streamRoom(ctx context.Context, roomManager *channels.RoomManager, playerId int32, lastEventID int64, out eventWriter) {
+	roomId := roomManager.RoomId
 
-	defer hr.logger.Info("SSE connection closed", "player_id", playerId, "room_id", roomId)
-	defer close(listen)
+	// listen for incoming SseEvents
+	listener := channels.NewListener(playerId, hr.stream)
+	resume := roomManager.Listen(listener, lastEventID)
+	missed, lastSent := resume.Missed, resume.LastEventID
+
+	defer hr.logger.Info("event stream closed", "player_id", playerId, "room_id", roomId)
+	defer listener.Close()
 	defer func() {
-		roomManager.Mu.Lock()
-		delete(roomManager.Listerners, playerId)
-		roomManager.Mu.Unlock()
+		roomManager.Unlisten(listener)
+		// the room ignores it while the player has other connections
 		go func() {
-			roomManager.Events <- events.PlayerLeft{PlayerId: playerId, RoomId: roomId}
+			roomManager.Publish(events.PlayerLeft{PlayerId: playerId, RoomId: roomId})
 		}()
 	}()
 
-	hr.logger.Info("SSE connection established", "player_id", playerId, "room_id", roomId)
-	// player joined event
-	go func() {
-		roomManager.Events <- events.PlayerJoined{PlayerID: playerId, RoomID: roomId}
-	}()
+	hr.logger.Info("event stream established", "player_id", playerId, "room_id", roomId, "connection_id", listener.ID, "last_event_id", lastEventID, "missed", len(missed))
+	// player joined event, further tabs of the player don't join again
+	if resume.FirstConnection {
+		go func() {
+			roomManager.Publish(events.PlayerJoined{PlayerID: playerId, RoomID: roomId})
+		}()
+	}
+
+	// new clients, and reconnecting ones that missed events no longer kept, start from a snapshot of the room.
+	// It carries the id of the last event it includes, the ones after it follow
+	if lastEventID == 0 || !resume.Complete {
+		snapshot, snapshotID, err := roomManager.Snapshot(ctx)
+		if err != nil {
+			hr.logger.Error("failed to take room snapshot", "error", err, "room_id", roomId)
+			return
+		}
+
+		event := events.NewSseEvent(events.ROOM_SNAPSHOT, roomId, snapshot)
+		event.ID = snapshotID
+		missed = []events.SseEvent{event}
+		lastSent = snapshotID
+	}
+	for _, event := range missed {
+		if err := out.writeEvent(event); err != nil {
+			hr.logger.Error("failed to replay event", "error", err, "player_id", playerId)
+			return
+		}
+	}
+
+	// heartbeats keep proxies from closing a quiet stream, clients ignore them
+	heartbeat := time.NewTicker(hr.stream.HeartbeatInterval)
+	defer heartbeat.Stop()
 
 	for {
 		select {
-		case <-r.Context().Done():
-			hr.logger.Info("SSE client disconnected", "player_id", playerId, "room_id", roomId)
+		case <-ctx.Done():
+			hr.logger.Info("client disconnected", "player_id", playerId, "room_id", roomId)
 			// player left event
 			return
-		case event, ok := <-listen:
-			if !ok {
-				hr.logger.Info("SSE client disconnected", "player_id", playerId, "room_id", roomId)
+		case <-roomManager.Done():
+			// the room queued its final event on the listener before stopping, it goes out with what is left
+			hr.logger.Info("room stopped, closing event stream", "player_id", playerId, "room_id", roomId)
+			writeQueued(listener, out)
+			return
+		case <-listener.Dropped():
+			// the player was kicked, or the queue stayed full for too long and the client reconnects
+			// to catch up from its last event. What was queued before goes out first
+			hr.logger.Warn("event stream dropped by the room", "player_id", playerId, "room_id", roomId, "reason", listener.Reason(), "last_event_id", lastSent)
+			if err := writeQueued(listener, out); err != nil {
 				return
 			}
-
-			hr.logger.Info("Sending event to player's client", "player_id", playerId, "event", event, "room_id", roomId)
-			data, err := json.Marshal(event)
-			if err != nil {
-				hr.logger.Error("failed to marshal SSE event", "error", err, "player_id", playerId)
-				return // Client is likely gone, so exit
+			out.writeEvent(events.NewSseEvent(events.STREAM_CLOSED, roomId, events.StreamClosedPayload{
+				Reason: listener.Reason(),
+			}))
+			return
+		case <-heartbeat.C:
+			if err := out.writeHeartbeat(); err != nil {
+				return
 			}
-
-			if event.EventType != "" {
-				fmt.Fprintf(w, "event: %s\n", event.EventType)
+		case event := <-listener.Events():
+			if event.ID != 0 && event.ID <= lastSent {
+				// already part of the snapshot
+				continue
+			}
+			// make up for the events sent while the listener was not ready
+			pending := []events.SseEvent{event}
+			if event.ID > lastSent+1 {
+				pending = append(roomManager.Replay(playerId, lastSent, event.ID), event)
 			}
 
-			fmt.Fprintf(w, "data: %s\n\n", string(data))
+			for _, e := range pending {
+				hr.logger.Info("Sending event to player's client", "player_id", playerId, "event", e, "room_id", roomId)
+				if err := out.writeEvent(e); err != nil {
+					hr.logger.Error("failed to send event", "error", err, "player_id", playerId)
+					return // Client is likely gone, so exit
+				}
+				lastSent = e.ID
+			}
+		}
+	}
+}
 
-			w.(http.Flusher).Flush()
+// writeQueued sends the events still queued on the listener
+func writeQueued(listener *channels.Listener, out eventWriter) error {
+	for queued := len(listener.Events()); queued > 0; queued-- {
+		if err := out.writeEvent(<-listener.Events()); err != nil {
+			return err
 		}
 	}
+	return nil
+}
+
+// sseWriter sends events as Server-Sent Events
+type sseWriter struct {
+	w http.ResponseWriter
+}
+
+// writeEvent writes the event and flushes it to the client
+func (s sseWriter) writeEvent(event events.SseEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if event.ID != 0 {
+		fmt.Fprintf(s.w, "id: %d\n", event.ID)
+	}
+	if event.EventType != "" {
+		fmt.Fprintf(s.w, "event: %s\n", event.EventType)
+	}
+
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", string(data)); err != nil {
+		return err
+	}
+
+	s.w.(http.Flusher).Flush()
+
+	return nil
+}
+
+// writeHeartbeat writes a comment, which clients ignore
+func (s sseWriter) writeHeartbeat() error {
+	if _, err := fmt.Fprint(s.w, ": heartbeat\n\n"); err != nil {
+		return err
+	}
+	s.w.(http.Flusher).Flush()
+	return nil
+}
+
+// getLastEventId reads the Last-Event-ID header, or the last_event_id query parameter, 0 if neither is set
+func getLastEventId(r *http.Request) (int64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// EventSchemaHandler serves the JSON schema of the events sent by EventHandler
+func (hr *HandlerRepo) EventSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(events.Schema)
+}
+
+// MetricsHandler serves the expvar metrics, among them the SSE ones, to admins
+func (hr *HandlerRepo) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if !hr.isAdmin(r) {
+		http.Error(w, "admin token required", http.StatusUnauthorized)
+		return
+	}
+
+	expvar.Handler().ServeHTTP(w, r)
 }
@@ -4,22 +4,34 @@ import (
 	"golang-realtime/internal/channels"
 	"golang-realtime/internal/store"
 	"log/slog"
+	"time"
+)
+
+const (
+	// defaultRunInterval is the minimum time between two custom input runs of a player
+	defaultRunInterval = 3 * time.Second
 )
 
 // HandlerRepo holds all the dependencies required by the handlers.
 // This includes the application logger, services like the RoomManager,
 // and the centralized store for data access.
 type HandlerRepo struct {
-	logger  *slog.Logger
-	gr      *channels.GlobalRooms
-	queries *store.Queries
+	logger     *slog.Logger
+	gr         *channels.GlobalRooms
+	queries    *store.Queries
+	runLimiter *rateLimiter
+	adminToken string // empty disables every admin endpoint
+	stream     channels.StreamOptions
 }
 
 // NewHandlerRepo creates a new HandlerRepo with the provided dependencies.
-func NewHandlerRepo(logger *slog.Logger, gr *channels.GlobalRooms, queries *store.Queries) *HandlerRepo {
+func NewHandlerRepo(logger *slog.Logger, gr *channels.GlobalRooms, queries *store.Queries, adminToken string, stream channels.StreamOptions) *HandlerRepo {
 	return &HandlerRepo{
-		logger:  logger,
-		gr:      gr,
-		queries: queries,
+		logger:     logger,
+		gr:         gr,
+		queries:    queries,
+		runLimiter: newRateLimiter(defaultRunInterval),
+		adminToken: adminToken,
+		stream:     stream,
 	}
 }
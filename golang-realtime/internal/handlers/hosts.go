@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"errors"
+	"golang-realtime/internal/store"
+	"golang-realtime/pkg/common/request"
+	"golang-realtime/pkg/common/response"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	errNotRoomHost  = errors.New("only the room's hosts can do this")
+	errNotRoomOwner = errors.New("only the room's owner can do this")
+)
+
+type AddRoomHostRequest struct {
+	PlayerId int32 `json:"player_id"`
+}
+
+// requestPlayerId returns the id of the player making the request, taken from the player_id query parameter.
+// Known limitation: there is no authentication yet, so the caller is whoever they claim to be and the host checks
+// only keep honest clients in line. Once players log in, the id must come from the session instead
+func requestPlayerId(r *http.Request) (int32, error) {
+	playerId, err := strconv.ParseInt(r.URL.Query().Get("player_id"), 10, 32)
+	if err != nil {
+		return 0, errors.New("invalid player_id")
+	}
+	return int32(playerId), nil
+}
+
+// authorizeRoomHost writes an error response and returns false unless the caller is a host of the room.
+// Admins may act on any room
+func (hr *HandlerRepo) authorizeRoomHost(w http.ResponseWriter, r *http.Request, roomId int32) bool {
+	if hr.isAdmin(r) {
+		return true
+	}
+
+	playerId, err := requestPlayerId(r)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return false
+	}
+
+	isHost, err := hr.queries.IsRoomHost(r.Context(), store.IsRoomHostParams{
+		RoomID:   roomId,
+		PlayerID: playerId,
+	})
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return false
+	}
+	if !isHost {
+		response.JSON(w, http.StatusForbidden, nil, true, errNotRoomHost.Error())
+		return false
+	}
+
+	return true
+}
+
+// authorizeRoomOwner writes an error response and returns false unless the caller owns the room
+func (hr *HandlerRepo) authorizeRoomOwner(w http.ResponseWriter, r *http.Request, room store.Room) bool {
+	if hr.isAdmin(r) {
+		return true
+	}
+
+	playerId, err := requestPlayerId(r)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return false
+	}
+
+	if !room.OwnerID.Valid || room.OwnerID.Int32 != playerId {
+		response.JSON(w, http.StatusForbidden, nil, true, errNotRoomOwner.Error())
+		return false
+	}
+
+	return true
+}
+
+func (hr *HandlerRepo) ListRoomHostsHandler(w http.ResponseWriter, r *http.Request) {
+	roomId, err := strconv.ParseInt(chi.URLParam(r, "roomId"), 10, 32)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invalid room ID")
+		return
+	}
+
+	hosts, err := hr.queries.ListRoomHosts(r.Context(), int32(roomId))
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, hosts, false, "")
+}
+
+// AddRoomHostHandler makes a player co-host of the room, only the owner can do it
+func (hr *HandlerRepo) AddRoomHostHandler(w http.ResponseWriter, r *http.Request) {
+	room, ok := hr.ownedRoom(w, r)
+	if !ok {
+		return
+	}
+
+	var req AddRoomHostRequest
+	if err := request.DecodeJSON(w, r, &req); err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return
+	}
+
+	if _, err := hr.queries.GetPlayer(r.Context(), req.PlayerId); err != nil {
+		response.JSON(w, http.StatusNotFound, nil, true, "player not found")
+		return
+	}
+
+	err := hr.queries.AddRoomHost(r.Context(), store.AddRoomHostParams{
+		RoomID:   room.ID,
+		PlayerID: req.PlayerId,
+	})
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, nil, false, "host added")
+}
+
+// RemoveRoomHostHandler takes the co-host rights of a player away, only the owner can do it
+func (hr *HandlerRepo) RemoveRoomHostHandler(w http.ResponseWriter, r *http.Request) {
+	room, ok := hr.ownedRoom(w, r)
+	if !ok {
+		return
+	}
+
+	playerId, err := strconv.ParseInt(chi.URLParam(r, "playerId"), 10, 32)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invalid player id")
+		return
+	}
+
+	err = hr.queries.RemoveRoomHost(r.Context(), store.RemoveRoomHostParams{
+		RoomID:   room.ID,
+		PlayerID: int32(playerId),
+	})
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, nil, false, "host removed")
+}
+
+// ownedRoom loads the room of the request and checks the caller owns it
+func (hr *HandlerRepo) ownedRoom(w http.ResponseWriter, r *http.Request) (store.Room, bool) {
+	roomId, err := strconv.ParseInt(chi.URLParam(r, "roomId"), 10, 32)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invalid room ID")
+		return store.Room{}, false
+	}
+
+	room, err := hr.queries.GetRoom(r.Context(), int32(roomId))
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, nil, true, "room not found")
+		return store.Room{}, false
+	}
+
+	if !hr.authorizeRoomOwner(w, r, room) {
+		return store.Room{}, false
+	}
+
+	return room, true
+}
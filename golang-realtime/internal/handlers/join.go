@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"golang-realtime/internal/channels"
+	"golang-realtime/internal/events"
+	"golang-realtime/internal/store"
+	"golang-realtime/pkg/common/request"
+	"golang-realtime/pkg/common/response"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+type JoinRoomRequest struct {
+	PlayerId   int32  `json:"player_id"`
+	InviteCode string `json:"invite_code"`
+	Password   string `json:"password"`
+}
+
+// JoinRoomHandler takes a seat in the room, players must join before opening the event stream
+func (hr *HandlerRepo) JoinRoomHandler(w http.ResponseWriter, r *http.Request) {
+	roomId, err := strconv.ParseInt(chi.URLParam(r, "roomId"), 10, 32)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invalid room ID")
+		return
+	}
+
+	var req JoinRoomRequest
+	if err := request.DecodeJSON(w, r, &req); err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return
+	}
+
+	room, err := hr.queries.GetRoom(r.Context(), int32(roomId))
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, nil, true, "room not found")
+		return
+	}
+
+	hr.joinRoom(w, r, room, req)
+}
+
+// JoinRoomByInviteCodeHandler joins the room the invite code belongs to
+func (hr *HandlerRepo) JoinRoomByInviteCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var req JoinRoomRequest
+	if err := request.DecodeJSON(w, r, &req); err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return
+	}
+
+	if req.InviteCode == "" {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invite code is required")
+		return
+	}
+
+	room, err := hr.queries.GetRoomByInviteCode(r.Context(), pgtype.Text{String: req.InviteCode, Valid: true})
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, nil, true, "room not found")
+		return
+	}
+
+	hr.joinRoom(w, r, room, req)
+}
+
+func (hr *HandlerRepo) joinRoom(w http.ResponseWriter, r *http.Request, room store.Room, req JoinRoomRequest) {
+	ctx := r.Context()
+
+	if _, err := hr.queries.GetPlayer(ctx, req.PlayerId); err != nil {
+		response.JSON(w, http.StatusNotFound, nil, true, "player not found")
+		return
+	}
+
+	// hosts get into their own rooms without the invite code
+	isHost, err := hr.queries.IsRoomHost(ctx, store.IsRoomHostParams{
+		RoomID:   room.ID,
+		PlayerID: req.PlayerId,
+	})
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+	if !isHost {
+		if err := channels.CheckRoomAccess(room, req.InviteCode, req.Password); err != nil {
+			response.JSON(w, http.StatusForbidden, nil, true, err.Error())
+			return
+		}
+	}
+
+	roomManager, ok := hr.roomManager(w, r, room.ID)
+	if !ok {
+		return
+	}
+
+	// the room's event loop has the final word on capacity
+	done := make(chan error, 1)
+	err = roomManager.Publish(events.PlayerJoined{PlayerID: req.PlayerId, RoomID: room.ID, Done: done})
+	if err == nil {
+		select {
+		case err = <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	switch {
+	case errors.Is(err, channels.ErrRoomFull), errors.Is(err, channels.ErrRoomClosed), errors.Is(err, channels.ErrRoomStopped):
+		response.JSON(w, http.StatusConflict, nil, true, err.Error())
+		return
+	case errors.Is(err, channels.ErrPlayerKicked):
+		response.JSON(w, http.StatusForbidden, nil, true, err.Error())
+		return
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return
+	case err != nil:
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, room, false, "join room successfully")
+}
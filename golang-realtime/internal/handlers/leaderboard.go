@@ -1,6 +1,8 @@
 package handlers
 
 import (
+	"errors"
+	"golang-realtime/internal/channels"
 	"golang-realtime/pkg/common/response"
 	"log/slog"
 	"net/http"
@@ -13,10 +15,12 @@ type LeaderboardEntry struct {
 	PlayerName string `json:"player_name"`
 	Score      int    `json:"score"`
 	Place      int    `json:"place"`
+	Penalty    int    `json:"penalty"` // penalty time in minutes, only used by ICPC rooms
 }
 
 type LeaderboardResponse struct {
 	Entries []LeaderboardEntry `json:"entries"`
+	Frozen  bool               `json:"frozen"`
 }
 
 func (hr *HandlerRepo) GetLeaderboardHandler(w http.ResponseWriter, r *http.Request) {
@@ -37,6 +41,24 @@ func (hr *HandlerRepo) GetLeaderboardHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// players only see the standings of the freeze start, admins always see live ones
+	var frozen bool
+	if !hr.isAdmin(r) {
+		var standings channels.FrozenStandings
+		standings, frozen, err = hr.frozenStandings(ctx, int32(roomId))
+		switch {
+		case errors.Is(err, channels.ErrRoomNotFound):
+			response.JSON(w, http.StatusNotFound, nil, true, err.Error())
+			return
+		case err != nil:
+			response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+			return
+		}
+		if frozen {
+			dbEntries = standings.Leaderboard
+		}
+	}
+
 	// Convert database entries to response format
 	var entries []LeaderboardEntry
 	for _, dbEntry := range dbEntries {
@@ -44,12 +66,14 @@ func (hr *HandlerRepo) GetLeaderboardHandler(w http.ResponseWriter, r *http.Requ
 			PlayerName: dbEntry.Name,
 			Score:      int(dbEntry.Score.Int32), // Handle pgtype.Int4
 			Place:      int(dbEntry.Place.Int32), // Handle pgtype.Int4
+			Penalty:    int(dbEntry.Penalty),
 		}
 		entries = append(entries, entry)
 	}
 
 	res := LeaderboardResponse{
 		Entries: entries,
+		Frozen:  frozen,
 	}
 
 	response.JSON(w, http.StatusOK, res, false, "get leaderboard successfully")
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"golang-realtime/internal/channels"
+	"golang-realtime/internal/store"
+	"golang-realtime/pkg/common/request"
+	"golang-realtime/pkg/common/response"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type StartMatchRequest struct {
+	// CountdownSeconds before the match starts, channels.DefaultCountdown if not set
+	CountdownSeconds *int `json:"countdown_seconds"`
+}
+
+// StartMatchHandler starts the countdown of a room in the lobby
+func (hr *HandlerRepo) StartMatchHandler(w http.ResponseWriter, r *http.Request) {
+	var req StartMatchRequest
+	if r.ContentLength > 0 {
+		if err := request.DecodeJSON(w, r, &req); err != nil {
+			response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+			return
+		}
+	}
+
+	countdown := channels.DefaultCountdown
+	if req.CountdownSeconds != nil {
+		if *req.CountdownSeconds < 0 {
+			response.JSON(w, http.StatusBadRequest, nil, true, "countdown can't be negative")
+			return
+		}
+		countdown = time.Duration(*req.CountdownSeconds) * time.Second
+	}
+
+	hr.changeMatchState(w, r, "match countdown started", func(ctx context.Context, rm *channels.RoomManager) (store.Room, error) {
+		return rm.StartMatch(ctx, countdown)
+	})
+}
+
+// FinishMatchHandler ends a running match before its time is up
+func (hr *HandlerRepo) FinishMatchHandler(w http.ResponseWriter, r *http.Request) {
+	hr.changeMatchState(w, r, "match finished", func(ctx context.Context, rm *channels.RoomManager) (store.Room, error) {
+		return rm.FinishMatch(ctx)
+	})
+}
+
+// ArchiveRoomHandler archives a finished room
+func (hr *HandlerRepo) ArchiveRoomHandler(w http.ResponseWriter, r *http.Request) {
+	hr.changeMatchState(w, r, "room archived", func(ctx context.Context, rm *channels.RoomManager) (store.Room, error) {
+		return rm.ArchiveRoom(ctx)
+	})
+}
+
+func (hr *HandlerRepo) changeMatchState(w http.ResponseWriter, r *http.Request, msg string, change func(ctx context.Context, rm *channels.RoomManager) (store.Room, error)) {
+	roomId, err := strconv.ParseInt(chi.URLParam(r, "roomId"), 10, 32)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invalid room ID")
+		return
+	}
+
+	rm, ok := hr.roomManager(w, r, int32(roomId))
+	if !ok {
+		return
+	}
+
+	if !hr.authorizeRoomHost(w, r, int32(roomId)) {
+		return
+	}
+
+	room, err := change(r.Context(), rm)
+	switch {
+	case errors.Is(err, channels.ErrInvalidTransition), errors.Is(err, channels.ErrNoQuestionsToDraw):
+		response.JSON(w, http.StatusConflict, nil, true, err.Error())
+		return
+	case err != nil:
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, room, false, msg)
+}
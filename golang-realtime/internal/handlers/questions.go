@@ -3,6 +3,9 @@ package handlers
 import (
 	"golang-realtime/pkg/common/response"
 	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
 )
 
 func (hr *HandlerRepo) ListQuestionsHandler(w http.ResponseWriter, r *http.Request) {
@@ -14,3 +17,43 @@ func (hr *HandlerRepo) ListQuestionsHandler(w http.ResponseWriter, r *http.Reque
 
 	response.JSON(w, http.StatusOK, questions, false, "")
 }
+
+// ListRoomQuestionsHandler returns the question set of a room in order, with the points each question is worth there.
+// A room without a question set is open to the whole catalog
+func (hr *HandlerRepo) ListRoomQuestionsHandler(w http.ResponseWriter, r *http.Request) {
+	roomId, err := strconv.ParseInt(chi.URLParam(r, "roomId"), 10, 32)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invalid room ID")
+		return
+	}
+
+	if _, err := hr.queries.GetRoom(r.Context(), int32(roomId)); err != nil {
+		response.JSON(w, http.StatusNotFound, nil, true, "room not found")
+		return
+	}
+
+	questions, err := hr.queries.ListRoomQuestions(r.Context(), int32(roomId))
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, questions, false, "")
+}
+
+// ListSampleTestCasesHandler returns the public sample tests of a question, hidden tests are never exposed
+func (hr *HandlerRepo) ListSampleTestCasesHandler(w http.ResponseWriter, r *http.Request) {
+	questionId, err := strconv.ParseInt(chi.URLParam(r, "questionId"), 10, 32)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invalid question id")
+		return
+	}
+
+	samples, err := hr.queries.ListSampleTestCasesForQuestion(r.Context(), int32(questionId))
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, samples, false, "")
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// rateLimiter allows one action per key every interval
+type rateLimiter struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[string]time.Time
+}
+
+func newRateLimiter(interval time.Duration) *rateLimiter {
+	return &rateLimiter{
+		interval: interval,
+		last:     make(map[string]time.Time),
+	}
+}
+
+// Allow reports whether every one of the keys may act now, and how long to wait otherwise.
+// The action counts for all the keys, only when it is allowed
+func (rl *rateLimiter) Allow(keys ...string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range keys {
+		if last, ok := rl.last[key]; ok {
+			wait = max(wait, rl.interval-now.Sub(last))
+		}
+	}
+	if wait > 0 {
+		return false, wait
+	}
+
+	for _, key := range keys {
+		rl.last[key] = now
+	}
+
+	// drop stale entries so the map does not grow with every key ever seen
+	for key, t := range rl.last {
+		if now.Sub(t) > rl.interval {
+			delete(rl.last, key)
+		}
+	}
+
+	return true, 0
+}
+
+// clientAddr is the address the request came from, without its port
+func clientAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
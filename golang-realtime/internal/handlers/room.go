@@ -2,7 +2,10 @@ package handlers
 
 import (
 	"context"
+	"errors"
+	"golang-realtime/internal/channels"
 	"golang-realtime/internal/events"
+	"golang-realtime/internal/scoring"
 	"golang-realtime/pkg/common/request"
 	"golang-realtime/pkg/common/response"
 	"net/http"
@@ -16,7 +19,14 @@ import (
 
 func (hr *HandlerRepo) ListRoomsHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := context.Background()
-	rooms, err := hr.queries.ListRooms(ctx)
+
+	// private and unlisted rooms are only found through their id or invite code
+	listRooms := hr.queries.ListPublicRooms
+	if hr.isAdmin(r) {
+		listRooms = hr.queries.ListRooms
+	}
+
+	rooms, err := listRooms(ctx)
 	if err != nil {
 		response.JSON(w, http.StatusInternalServerError, nil, true, "Failed to get rooms: "+err.Error())
 		return
@@ -29,8 +39,24 @@ func (hr *HandlerRepo) ListRoomsHandler(w http.ResponseWriter, r *http.Request)
 }
 
 type CreateRoomRequest struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	Name          string `json:"name"`
+	Description   string `json:"description"`
+	FeedbackLevel string `json:"feedback_level"`
+	ScoringMode   string `json:"scoring_mode"`
+	// PlayerId of the creator, who becomes the room's owner
+	PlayerId int32 `json:"player_id"`
+	// DurationMinutes is how long the match runs once started, 0 keeps it running until finished by hand
+	DurationMinutes int `json:"duration_minutes"`
+	// FreezeMinutes freezes the scoreboard for players during the final minutes of the room
+	FreezeMinutes int `json:"freeze_minutes"`
+	// Visibility is public, unlisted or private, non public rooms get an invite code
+	Visibility string `json:"visibility"`
+	// Password optionally lets players join a private room without the invite code
+	Password string `json:"password"`
+	// MaxPlayers caps the number of players in the room, 0 means no limit
+	MaxPlayers int `json:"max_players"`
+	// Draw makes a quick match, its questions are drawn at random when it starts
+	Draw *QuestionDrawRequest `json:"draw"`
 }
 
 func (hr *HandlerRepo) CreateRoomHandler(w http.ResponseWriter, r *http.Request) {
@@ -42,6 +68,72 @@ func (hr *HandlerRepo) CreateRoomHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	feedbackLevel, err := channels.ParseFeedbackLevel(req.FeedbackLevel)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return
+	}
+
+	scoringMode, err := scoring.ParseMode(req.ScoringMode)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return
+	}
+
+	if _, err := hr.queries.GetPlayer(r.Context(), req.PlayerId); err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "player not found")
+		return
+	}
+
+	visibility, err := channels.ParseVisibility(req.Visibility)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return
+	}
+
+	if req.MaxPlayers < 0 {
+		response.JSON(w, http.StatusBadRequest, nil, true, "max players can't be negative")
+		return
+	}
+
+	var inviteCode pgtype.Text
+	if visibility != channels.VisibilityPublic {
+		code, err := channels.NewInviteCode()
+		if err != nil {
+			response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+			return
+		}
+		inviteCode = pgtype.Text{String: code, Valid: true}
+	}
+
+	var password pgtype.Text
+	if req.Password != "" {
+		hash, err := channels.HashRoomPassword(req.Password)
+		if err != nil {
+			response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+			return
+		}
+		password = pgtype.Text{String: hash, Valid: true}
+	}
+
+	if req.DurationMinutes < 0 || req.FreezeMinutes < 0 {
+		response.JSON(w, http.StatusBadRequest, nil, true, "duration and freeze minutes can't be negative")
+		return
+	}
+	if req.FreezeMinutes > 0 && req.FreezeMinutes > req.DurationMinutes {
+		response.JSON(w, http.StatusBadRequest, nil, true, "freeze minutes must fit in the room duration")
+		return
+	}
+
+	var draw store.UpdateRoomDrawParams
+	if req.Draw != nil {
+		draw, err = drawParams(0, *req.Draw)
+		if err != nil {
+			response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+			return
+		}
+	}
+
 	ctx := context.Background()
 
 	// Generate a random ID for the room
@@ -56,8 +148,17 @@ func (hr *HandlerRepo) CreateRoomHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	createParams := store.CreateRoomParams{
-		Name:        req.Name,
-		Description: description,
+		Name:            req.Name,
+		Description:     description,
+		FeedbackLevel:   string(feedbackLevel),
+		ScoringMode:     string(scoringMode),
+		DurationMinutes: int32(req.DurationMinutes),
+		FreezeMinutes:   int32(req.FreezeMinutes),
+		OwnerID:         pgtype.Int4{Int32: req.PlayerId, Valid: true},
+		Visibility:      string(visibility),
+		InviteCode:      inviteCode,
+		Password:        password,
+		MaxPlayers:      int32(req.MaxPlayers),
 	}
 
 	newRoom, err := hr.queries.CreateRoom(ctx, createParams)
@@ -66,11 +167,17 @@ func (hr *HandlerRepo) CreateRoomHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Create a room manager for the new room
-	roomManager := hr.gr.CreateRoom(newRoom.ID, hr.queries)
-	go func() {
-		roomManager.Start()
-	}()
+	if req.Draw != nil {
+		draw.ID = newRoom.ID
+		newRoom, err = hr.queries.UpdateRoomDraw(ctx, draw)
+		if err != nil {
+			response.JSON(w, http.StatusInternalServerError, nil, true, "Failed to save room draw: "+err.Error())
+			return
+		}
+	}
+
+	// Create a room manager for the new room, it starts its event loop
+	hr.gr.CreateRoom(newRoom.ID, hr.queries)
 
 	err = response.JSON(w, http.StatusCreated, newRoom, false, "create room successfully")
 	if err != nil {
@@ -78,6 +185,20 @@ func (hr *HandlerRepo) CreateRoomHandler(w http.ResponseWriter, r *http.Request)
 	}
 }
 
+// roomManager writes an error response and returns false if the room has no manager and can't be given one
+func (hr *HandlerRepo) roomManager(w http.ResponseWriter, r *http.Request, roomId int32) (*channels.RoomManager, bool) {
+	rm, err := hr.gr.GetOrLoadRoom(r.Context(), roomId)
+	switch {
+	case errors.Is(err, channels.ErrRoomNotFound), errors.Is(err, channels.ErrRoomClosed):
+		response.JSON(w, http.StatusNotFound, nil, true, "room not found or not active")
+		return nil, false
+	case err != nil:
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return nil, false
+	}
+	return rm, true
+}
+
 func (hr *HandlerRepo) DeleteRoomHandler(w http.ResponseWriter, r *http.Request) {
 	roomIdStr := chi.URLParam(r, "roomId") // Fixed parameter name
 	roomId, err := strconv.ParseInt(roomIdStr, 10, 32)
@@ -95,9 +216,12 @@ func (hr *HandlerRepo) DeleteRoomHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	roomManager := hr.gr.GetRoomById(int32(roomId))
-	if roomManager == nil {
-		response.JSON(w, http.StatusNotFound, nil, true, "room not found")
+	if !hr.authorizeRoomHost(w, r, int32(roomId)) {
+		return
+	}
+
+	roomManager, ok := hr.roomManager(w, r, int32(roomId))
+	if !ok {
 		return
 	}
 
@@ -105,7 +229,7 @@ func (hr *HandlerRepo) DeleteRoomHandler(w http.ResponseWriter, r *http.Request)
 		e := events.RoomDeleted{
 			RoomId: int32(roomId),
 		}
-		roomManager.Events <- e
+		roomManager.Publish(e)
 	}()
 
 	response.JSON(w, http.StatusOK, nil, false, "delete room successfully")
@@ -130,9 +254,8 @@ func (hr *HandlerRepo) LeaveRoomHandler(w http.ResponseWriter, r *http.Request)
 	ctx := context.Background()
 
 	// Check if room exists before attempting to leave
-	roomManager := hr.gr.GetRoomById(int32(roomId))
-	if roomManager == nil {
-		response.JSON(w, http.StatusNotFound, nil, false, "room not found")
+	roomManager, ok := hr.roomManager(w, r, int32(roomId))
+	if !ok {
 		return
 	}
 
@@ -143,12 +266,35 @@ func (hr *HandlerRepo) LeaveRoomHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	callerId, err := requestPlayerId(r)
+	if err != nil && !hr.isAdmin(r) {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return
+	}
+
+	// removing anyone but yourself is a kick, which only hosts can do
+	if callerId != int32(playerId) {
+		if !hr.authorizeRoomHost(w, r, int32(roomId)) {
+			return
+		}
+
+		go func() {
+			roomManager.Publish(events.PlayerKicked{
+				PlayerId: int32(playerId),
+				RoomId:   int32(roomId),
+			})
+		}()
+
+		response.JSON(w, http.StatusOK, nil, false, "player kicked successfully")
+		return
+	}
+
 	go func() {
 		e := events.PlayerLeft{
 			PlayerId: int32(playerId),
 			RoomId:   int32(roomId),
 		}
-		roomManager.Events <- e
+		roomManager.Publish(e)
 	}()
 
 	response.JSON(w, http.StatusOK, nil, false, "leave room successfully")
@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"golang-realtime/internal/channels"
+	"golang-realtime/internal/executor"
+	"golang-realtime/pkg/common/request"
+	"golang-realtime/pkg/common/response"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type RunCodeRequest struct {
+	QuestionId int32  `json:"question_id"`
+	PlayerId   int32  `json:"player_id"`
+	Language   string `json:"language"`
+	Code       string `json:"code"`
+	Input      string `json:"input"`
+}
+
+type RunCodeResponse struct {
+	Stdout        string `json:"stdout"`
+	Stderr        string `json:"stderr"`
+	ExitCode      int    `json:"exit_code"`
+	ExecutionTime string `json:"execution_time"`
+}
+
+var (
+	errTooManyRuns    = errors.New("too many runs, slow down")
+	errPlayerNotFound = errors.New("player not found")
+)
+
+// RunCodeHandler executes the player's code against a custom input without touching the leaderboard
+func (hr *HandlerRepo) RunCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var req RunCodeRequest
+	if err := request.DecodeJSON(w, r, &req); err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return
+	}
+
+	res, wait, err := hr.runCode(r.Context(), req, clientAddr(r))
+	switch {
+	case errors.Is(err, errTooManyRuns):
+		headers := http.Header{}
+		headers.Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		response.JSONWithHeaders(w, http.StatusTooManyRequests, nil, true, err.Error(), headers)
+		return
+	case errors.Is(err, channels.ErrLanguageNotFound):
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return
+	case errors.Is(err, errPlayerNotFound), errors.Is(err, channels.ErrQuestionNotFound):
+		response.JSON(w, http.StatusNotFound, nil, true, err.Error())
+		return
+	case errors.Is(err, executor.ErrJobQueueFull):
+		response.JSON(w, http.StatusServiceUnavailable, nil, true, err.Error())
+		return
+	case err != nil:
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, res, false, "run completed")
+}
+
+// runCode runs the code of the player. The player and the address the request came from may each only run
+// once per interval, so made up player ids don't get around the limit. With errTooManyRuns,
+// the returned duration is how long the player has to wait
+func (hr *HandlerRepo) runCode(ctx context.Context, req RunCodeRequest, addr string) (RunCodeResponse, time.Duration, error) {
+	if _, err := hr.queries.GetPlayer(ctx, req.PlayerId); err != nil {
+		return RunCodeResponse{}, 0, errPlayerNotFound
+	}
+	if ok, wait := hr.runLimiter.Allow("player:"+strconv.Itoa(int(req.PlayerId)), "addr:"+addr); !ok {
+		return RunCodeResponse{}, wait, errTooManyRuns
+	}
+
+	result, err := hr.gr.RunCode(ctx, req.QuestionId, req.Language, req.Code, &req.Input)
+	if err != nil {
+		return RunCodeResponse{}, 0, err
+	}
+
+	return RunCodeResponse{
+		Stdout:        result.Stdout,
+		Stderr:        result.Stderr,
+		ExitCode:      result.ExitCode,
+		ExecutionTime: result.ExecutionTime,
+	}, 0, nil
+}
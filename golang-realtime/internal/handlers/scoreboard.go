@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"golang-realtime/internal/channels"
+	"golang-realtime/internal/store"
+	"golang-realtime/pkg/common/response"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// ScoreboardCell is the state of a player on a single question
+type ScoreboardCell struct {
+	QuestionID   int32  `json:"question_id"`
+	Attempts     int32  `json:"attempts"` // rejected attempts, plus the accepted one if solved
+	Solved       bool   `json:"solved"`
+	SolveMinutes *int32 `json:"solve_minutes"` // minutes from the match start, null if not solved
+	FirstToSolve bool   `json:"first_to_solve"`
+	Score        int32  `json:"score"`
+}
+
+type ScoreboardRow struct {
+	PlayerID   int32            `json:"player_id"`
+	PlayerName string           `json:"player_name"`
+	Place      int              `json:"place"`
+	Score      int              `json:"score"`
+	Solved     int              `json:"solved"`
+	Penalty    int              `json:"penalty"`
+	Problems   []ScoreboardCell `json:"problems"` // one cell per entry of QuestionIDs, in the same order
+}
+
+type ScoreboardResponse struct {
+	ScoringMode string          `json:"scoring_mode"`
+	QuestionIDs []int32         `json:"question_ids"`
+	Rows        []ScoreboardRow `json:"rows"`
+	Frozen      bool            `json:"frozen"`
+}
+
+type scoreboardKey struct {
+	playerID   int32
+	questionID int32
+}
+
+// GetScoreboardHandler returns the per question matrix of every player in the room, ordered by place
+func (hr *HandlerRepo) GetScoreboardHandler(w http.ResponseWriter, r *http.Request) {
+	roomId, err := strconv.ParseInt(chi.URLParam(r, "roomId"), 10, 32)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invalid room ID")
+		return
+	}
+
+	ctx := r.Context()
+	room, err := hr.queries.GetRoom(ctx, int32(roomId))
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, nil, true, "room not found")
+		return
+	}
+
+	players, err := hr.queries.GetLeaderboardForRoom(ctx, room.ID)
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	questionScores, err := hr.queries.ListRoomQuestionScores(ctx, room.ID)
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	solves, err := hr.queries.ListRoomSolves(ctx, room.ID)
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	roomQuestions, err := hr.queries.ListRoomQuestions(ctx, room.ID)
+	if err != nil {
+		response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+		return
+	}
+
+	// players only see the standings of the freeze start, admins always see live ones
+	var frozen bool
+	if !hr.isAdmin(r) {
+		var standings channels.FrozenStandings
+		standings, frozen, err = hr.frozenStandings(ctx, room.ID)
+		if err != nil {
+			response.JSON(w, http.StatusInternalServerError, nil, true, err.Error())
+			return
+		}
+		if frozen {
+			players = standings.Leaderboard
+			questionScores = standings.QuestionScores
+			solves = standings.Solves
+		}
+	}
+
+	res := buildScoreboard(room, channels.RoomQuestionIds(roomQuestions), players, questionScores, solves)
+	res.Frozen = frozen
+
+	response.JSON(w, http.StatusOK, res, false, "get scoreboard successfully")
+}
+
+// frozenStandings returns the standings players see in the room, false if the scoreboard is live.
+// It loads the manager of an evicted room, whose freeze may still wait for its reveal
+func (hr *HandlerRepo) frozenStandings(ctx context.Context, roomId int32) (channels.FrozenStandings, bool, error) {
+	rm, err := hr.gr.GetOrLoadRoom(ctx, roomId)
+	if errors.Is(err, channels.ErrRoomClosed) {
+		// archived rooms get no manager, their standings are final
+		return channels.FrozenStandings{}, false, nil
+	}
+	if err != nil {
+		return channels.FrozenStandings{}, false, err
+	}
+
+	standings, frozen := rm.Frozen()
+	return standings, frozen, nil
+}
+
+// buildScoreboard lays the questions out in the order of the room's question set,
+// rooms without one get a column for every question played, by id
+func buildScoreboard(room store.Room, roomQuestionIDs []int32, players []store.GetLeaderboardForRoomRow, questionScores []store.RoomQuestionScore, solves []store.RoomSolve) ScoreboardResponse {
+	cells := make(map[scoreboardKey]*ScoreboardCell)
+	questionIDs := slices.Clone(roomQuestionIDs)
+
+	cell := func(playerID, questionID int32) *ScoreboardCell {
+		key := scoreboardKey{playerID: playerID, questionID: questionID}
+		c, ok := cells[key]
+		if !ok {
+			c = &ScoreboardCell{QuestionID: questionID}
+			cells[key] = c
+		}
+		if !slices.Contains(questionIDs, questionID) {
+			questionIDs = append(questionIDs, questionID)
+		}
+		return c
+	}
+
+	for _, qs := range questionScores {
+		c := cell(qs.PlayerID, qs.QuestionID)
+		c.Attempts += qs.WrongAttempts
+		c.Score = qs.BestScore
+	}
+
+	// solves are ordered by solve time, so the first one of each question is the first to solve
+	firstSolved := make(map[int32]bool)
+	for _, solve := range solves {
+		c := cell(solve.PlayerID, solve.QuestionID)
+		c.Attempts++
+		c.Solved = true
+		c.Score = solve.AwardedPoints
+		c.FirstToSolve = !firstSolved[solve.QuestionID]
+		firstSolved[solve.QuestionID] = true
+
+		if room.StartedAt.Valid && solve.SolvedAt.Valid {
+			minutes := max(int32(solve.SolvedAt.Time.Sub(room.StartedAt.Time).Minutes()), 0)
+			c.SolveMinutes = &minutes
+		}
+	}
+
+	if len(roomQuestionIDs) == 0 {
+		slices.Sort(questionIDs)
+	}
+
+	rows := make([]ScoreboardRow, 0, len(players))
+	for _, player := range players {
+		row := ScoreboardRow{
+			PlayerID:   player.PlayerID,
+			PlayerName: player.Name,
+			Place:      int(player.Place.Int32),
+			Score:      int(player.Score.Int32),
+			Penalty:    int(player.Penalty),
+			Problems:   make([]ScoreboardCell, 0, len(questionIDs)),
+		}
+
+		for _, questionID := range questionIDs {
+			c, ok := cells[scoreboardKey{playerID: player.PlayerID, questionID: questionID}]
+			if !ok {
+				c = &ScoreboardCell{QuestionID: questionID}
+			}
+			if c.Solved {
+				row.Solved++
+			}
+			row.Problems = append(row.Problems, *c)
+		}
+
+		rows = append(rows, row)
+	}
+
+	return ScoreboardResponse{
+		ScoringMode: room.ScoringMode,
+		QuestionIDs: questionIDs,
+		Rows:        rows,
+	}
+}
@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"golang-realtime/internal/channels"
+	"golang-realtime/internal/scoring"
+	service "golang-realtime/internal/services"
+	"golang-realtime/internal/store"
+	"golang-realtime/pkg/common/request"
+	"golang-realtime/pkg/common/response"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// UpdateRoomSettingsRequest is a partial update, fields left out keep their current value
+type UpdateRoomSettingsRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	// AllowedLanguages restricts the languages players may submit in, empty allows every language
+	AllowedLanguages *[]string `json:"allowed_languages"`
+	// Questions replaces the question set of the room, in order, empty opens the room to the whole catalog
+	Questions       *[]RoomQuestionRequest `json:"questions"`
+	ScoringMode     *string                `json:"scoring_mode"`
+	DurationMinutes *int                   `json:"duration_minutes"`
+	FeedbackLevel   *string                `json:"feedback_level"`
+	// MaxSubmissions per player and question, 0 means no limit
+	MaxSubmissions *int `json:"max_submissions"`
+	// WrongAnswerCooldownSeconds a player waits on a question after a wrong answer
+	WrongAnswerCooldownSeconds *int `json:"wrong_answer_cooldown_seconds"`
+	// Draw picks the questions at random when the match starts, unless the room has a question set
+	Draw *QuestionDrawRequest `json:"draw"`
+}
+
+type RoomQuestionRequest struct {
+	QuestionId int32 `json:"question_id"`
+	// Points the question is worth in this room, the question's own score if not set
+	Points *int `json:"points"`
+}
+
+// QuestionDrawRequest asks for Count questions drawn from the catalog, balanced across difficulties.
+// A max difficulty of 0 has no upper bound, no tags allow every question
+type QuestionDrawRequest struct {
+	Count         int      `json:"count"`
+	MinDifficulty int      `json:"min_difficulty"`
+	MaxDifficulty int      `json:"max_difficulty"`
+	Tags          []string `json:"tags"`
+}
+
+// drawParams validates the draw of a room, a count of 0 turns the draw off
+func drawParams(roomId int32, req QuestionDrawRequest) (store.UpdateRoomDrawParams, error) {
+	if req.Count < 0 || req.MinDifficulty < 0 || req.MaxDifficulty < 0 {
+		return store.UpdateRoomDrawParams{}, errors.New("draw count and difficulties can't be negative")
+	}
+	if req.MaxDifficulty > 0 && req.MaxDifficulty < req.MinDifficulty {
+		return store.UpdateRoomDrawParams{}, errors.New("draw max difficulty is below the min difficulty")
+	}
+
+	tags := make([]string, 0, len(req.Tags))
+	for _, tag := range req.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return store.UpdateRoomDrawParams{
+		ID:                roomId,
+		DrawCount:         int32(req.Count),
+		DrawMinDifficulty: int32(req.MinDifficulty),
+		DrawMaxDifficulty: int32(req.MaxDifficulty),
+		DrawTags:          tags,
+	}, nil
+}
+
+// UpdateRoomSettingsHandler changes the settings of a room, only its hosts can do it.
+// Scoring mode, duration and question set are locked once the match has started
+func (hr *HandlerRepo) UpdateRoomSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	roomId, err := strconv.ParseInt(chi.URLParam(r, "roomId"), 10, 32)
+	if err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, "invalid room ID")
+		return
+	}
+
+	room, err := hr.queries.GetRoom(r.Context(), int32(roomId))
+	if err != nil {
+		response.JSON(w, http.StatusNotFound, nil, true, "room not found")
+		return
+	}
+
+	if !hr.authorizeRoomHost(w, r, room.ID) {
+		return
+	}
+
+	var req UpdateRoomSettingsRequest
+	if err := request.DecodeJSON(w, r, &req); err != nil {
+		response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+		return
+	}
+
+	var update channels.SettingsUpdate
+
+	if req.Name != nil {
+		if *req.Name == "" {
+			response.JSON(w, http.StatusBadRequest, nil, true, "name can't be empty")
+			return
+		}
+		update.Name = req.Name
+	}
+
+	if req.Description != nil {
+		update.Description = &pgtype.Text{String: *req.Description, Valid: *req.Description != ""}
+	}
+
+	if req.AllowedLanguages != nil {
+		languages, err := hr.allowedLanguages(r, *req.AllowedLanguages)
+		if err != nil {
+			response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+			return
+		}
+		update.AllowedLanguages = &languages
+	}
+
+	if req.Questions != nil {
+		if err := hr.validateRoomQuestions(r, *req.Questions); err != nil {
+			response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+			return
+		}
+		questions := roomQuestions(*req.Questions)
+		update.Questions = &questions
+	}
+
+	if req.Draw != nil {
+		draw, err := drawParams(room.ID, *req.Draw)
+		if err != nil {
+			response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+			return
+		}
+		update.Draw = &draw
+	}
+
+	if req.ScoringMode != nil {
+		mode, err := scoring.ParseMode(*req.ScoringMode)
+		if err != nil {
+			response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+			return
+		}
+		scoringMode := string(mode)
+		update.ScoringMode = &scoringMode
+	}
+
+	if req.DurationMinutes != nil {
+		if *req.DurationMinutes < 0 {
+			response.JSON(w, http.StatusBadRequest, nil, true, "duration can't be negative")
+			return
+		}
+		if room.FreezeMinutes > 0 && int32(*req.DurationMinutes) < room.FreezeMinutes {
+			response.JSON(w, http.StatusBadRequest, nil, true, "freeze minutes must fit in the room duration")
+			return
+		}
+		duration := int32(*req.DurationMinutes)
+		update.DurationMinutes = &duration
+	}
+
+	if req.FeedbackLevel != nil {
+		level, err := channels.ParseFeedbackLevel(*req.FeedbackLevel)
+		if err != nil {
+			response.JSON(w, http.StatusBadRequest, nil, true, err.Error())
+			return
+		}
+		feedbackLevel := string(level)
+		update.FeedbackLevel = &feedbackLevel
+	}
+
+	if req.MaxSubmissions != nil {
+		if *req.MaxSubmissions < 0 {
+			response.JSON(w, http.StatusBadRequest, nil, true, "max submissions can't be negative")
+			return
+		}
+		maxSubmissions := int32(*req.MaxSubmissions)
+		update.MaxSubmissions = &maxSubmissions
+	}
+
+	if req.WrongAnswerCooldownSeconds != nil {
+		if *req.WrongAnswerCooldownSeconds < 0 {
+			response.JSON(w, http.StatusBadRequest, nil, true, "wrong answer cooldown can't be negative")
+			return
+		}
+		cooldown := int32(*req.WrongAnswerCooldownSeconds)
+		update.WrongAnswerCooldownSeconds = &cooldown
+	}
+
+	rm, ok := hr.roomManager(w, r, room.ID)
+	if !ok {
+		return
+	}
+
+	// the room checks its state and saves the update on its event loop
+	updated, err := rm.UpdateSettings(r.Context(), update)
+	switch {
+	case errors.Is(err, channels.ErrSettingsLocked), errors.Is(err, channels.ErrRoomStopped):
+		response.JSON(w, http.StatusConflict, nil, true, err.Error())
+		return
+	case err != nil:
+		response.JSON(w, http.StatusInternalServerError, nil, true, "Failed to update room: "+err.Error())
+		return
+	}
+
+	response.JSON(w, http.StatusOK, updated, false, "update room successfully")
+}
+
+// allowedLanguages normalizes the language names and checks every one of them is supported
+func (hr *HandlerRepo) allowedLanguages(r *http.Request, names []string) ([]string, error) {
+	languages := make([]string, 0, len(names))
+	for _, name := range names {
+		normalized := service.NormalizeLanguage(name)
+		if _, err := hr.queries.GetLanguageByName(r.Context(), normalized); err != nil {
+			return nil, fmt.Errorf("unknown language %q", name)
+		}
+		if !slices.Contains(languages, normalized) {
+			languages = append(languages, normalized)
+		}
+	}
+	return languages, nil
+}
+
+// validateRoomQuestions checks every question exists and appears only once
+func (hr *HandlerRepo) validateRoomQuestions(r *http.Request, questions []RoomQuestionRequest) error {
+	catalog, err := hr.queries.ListQuestions(r.Context())
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[int32]bool, len(questions))
+	for _, rq := range questions {
+		exists := slices.ContainsFunc(catalog, func(q store.Question) bool {
+			return q.ID == rq.QuestionId
+		})
+		if !exists {
+			return fmt.Errorf("question %d not found", rq.QuestionId)
+		}
+		if seen[rq.QuestionId] {
+			return fmt.Errorf("question %d appears twice", rq.QuestionId)
+		}
+		if rq.Points != nil && *rq.Points < 0 {
+			return fmt.Errorf("points of question %d can't be negative", rq.QuestionId)
+		}
+		seen[rq.QuestionId] = true
+	}
+	return nil
+}
+
+// roomQuestions turns the question set of the request into rows, the room numbers them in order
+func roomQuestions(questions []RoomQuestionRequest) []store.AddRoomQuestionParams {
+	rows := make([]store.AddRoomQuestionParams, 0, len(questions))
+	for _, rq := range questions {
+		var points pgtype.Int4
+		if rq.Points != nil {
+			points = pgtype.Int4{Int32: int32(*rq.Points), Valid: true}
+		}
+		rows = append(rows, store.AddRoomQuestionParams{
+			QuestionID: rq.QuestionId,
+			Points:     points,
+		})
+	}
+	return rows
+}
@@ -1,13 +1,23 @@
 package handlers
 
 import (
+	"context"
+	"errors"
 	"golang-realtime/internal/channels"
 	"golang-realtime/internal/events"
+	service "golang-realtime/internal/services"
+	"golang-realtime/internal/store"
 	"golang-realtime/pkg/common/request"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 )
 
+var (
+	errNotRoomPlayer = errors.New("join the room before submitting")
+)
+
 type SubmitSolutionRequest struct {
 	QuestionId  int32     `json:"question_id"` // Changed to int32
 	RoomId      int32     `json:"room_id"`     // Changed to int32
@@ -15,34 +25,102 @@ type SubmitSolutionRequest struct {
 	Code        string    `json:"code"`
 	PlayerId    int32     `json:"player_id"` // Changed to int32
 	SubmittedAt time.Time `json:"submitted_at"`
+	SampleOnly  bool      `json:"sample_only"` // Only run the sample tests, nothing is scored
 }
 
 func (hr *HandlerRepo) SubmitSolutionHandler(w http.ResponseWriter, r *http.Request) {
 	var req SubmitSolutionRequest
-	var roomManager *channels.RoomManager
 
 	if err := request.DecodeJSON(w, r, &req); err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	roomManager = hr.gr.GetRoomById(req.RoomId) // Use GetRoomById method instead of direct access
-	if roomManager == nil {
+	wait, err := hr.submitSolution(r.Context(), req)
+	switch {
+	case errors.Is(err, channels.ErrRoomNotFound):
 		http.Error(w, "Room not found", http.StatusNotFound)
 		return
+	case errors.Is(err, channels.ErrRoomNotRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, errNotRoomPlayer), errors.Is(err, channels.ErrPlayerKicked):
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	case errors.Is(err, channels.ErrLanguageNotAllowed), errors.Is(err, channels.ErrQuestionNotInRoom):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case errors.Is(err, channels.ErrSubmissionCooldown):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, channels.ErrSubmissionLimitReached):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, channels.ErrRoomStopped):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	// Immediately acknowledge the request to the client.
+	// Immediately acknowledge the request to the client, the verdict comes as a room event
 	w.WriteHeader(http.StatusAccepted)
+}
+
+// submitSolution queues the solution for judging in its room. With ErrSubmissionCooldown,
+// the returned duration is how long the player has to wait
+func (hr *HandlerRepo) submitSolution(ctx context.Context, req SubmitSolutionRequest) (time.Duration, error) {
+	roomManager, err := hr.gr.GetOrLoadRoom(ctx, req.RoomId)
+	if err != nil {
+		return 0, channels.ErrRoomNotFound
+	}
+
+	room, err := hr.queries.GetRoom(ctx, req.RoomId)
+	if err != nil {
+		return 0, channels.ErrRoomNotFound
+	}
+	if channels.RoomStatus(room.Status) != channels.StatusRunning {
+		return 0, channels.ErrRoomNotRunning
+	}
+
+	roomPlayer, err := hr.queries.GetRoomPlayer(ctx, store.GetRoomPlayerParams{
+		RoomID:   req.RoomId,
+		PlayerID: req.PlayerId,
+	})
+	if err != nil {
+		return 0, errNotRoomPlayer
+	}
+	if roomPlayer.State.String == channels.PlayerLeft {
+		return 0, channels.ErrPlayerKicked
+	}
+
+	language := service.NormalizeLanguage(req.Language)
+	wait, err := roomManager.AdmitSubmission(ctx, req.PlayerId, req.QuestionId, language, req.SampleOnly)
+	if err != nil {
+		return wait, err
+	}
 
 	// In a real application, you'd have more sophisticated validation logic here.
 	// insert event to room manager
-	roomManager.Events <- events.SolutionSubmitted{
+	err = roomManager.Publish(events.SolutionSubmitted{
 		PlayerId:      req.PlayerId,
 		RoomId:        req.RoomId,
 		QuestionId:    req.QuestionId,
 		Language:      req.Language,
 		Code:          req.Code,
-		SubmittedTime: req.SubmittedAt,
+		SubmittedTime: time.Now(), // the client's clock can't be trusted for time based scoring
+		SampleOnly:    req.SampleOnly,
+	})
+	if err != nil {
+		hr.logger.Warn("submission dropped, the room stopped", "room_id", req.RoomId, "player_id", req.PlayerId)
+		// it never reaches the judge, so it doesn't count towards the limit
+		if !req.SampleOnly {
+			roomManager.RefundSubmission(req.PlayerId, req.QuestionId)
+		}
+		return 0, err
 	}
+
+	return 0, nil
 }
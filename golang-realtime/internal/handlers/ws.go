@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"golang-realtime/internal/events"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsMaxMessageSize bounds what a client may send at once, submissions included
+	wsMaxMessageSize = 1 << 20
+	// wsWriteTimeout is how long a message may take to reach the client
+	wsWriteTimeout = 10 * time.Second
+	// wsMaxRuns is how many runs a socket may have going at once, the ones past it are refused
+	wsMaxRuns = 2
+)
+
+var (
+	errUnknownMessage = errors.New("unknown message type")
+	errNotSubscribed  = errors.New("subscribe to a room first")
+	errRunsInFlight   = errors.New("too many runs going on, wait for one to finish")
+)
+
+// The client messages of the WebSocket protocol
+const (
+	wsSubscribe   = "subscribe"   // data: wsSubscribeRequest, follow the events of a room, replacing the previous one
+	wsUnsubscribe = "unsubscribe" // stop following the room
+	wsSubmit      = "submit"      // data: SubmitSolutionRequest, the room defaults to the subscribed one
+	wsRun         = "run"         // data: RunCodeRequest, the reply may come after the ones to later requests
+	wsChat        = "chat"        // data: wsChatRequest, to the subscribed room
+	wsPing        = "ping"
+)
+
+// The server messages of the WebSocket protocol
+const (
+	wsEvent = "event" // event: a room event, the same as the data of an SSE message
+	wsAck   = "ack"   // data: the result of the request, if any
+	wsError = "error" // error: what went wrong, retry_after: seconds to wait before trying again, if set
+	wsPong  = "pong"
+)
+
+// wsRequest is a message of the client, the reply carries its id
+type wsRequest struct {
+	Type string          `json:"type"`
+	ID   string          `json:"id,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+type wsReply struct {
+	Type       string           `json:"type"`
+	ID         string           `json:"id,omitempty"`
+	Event      *events.SseEvent `json:"event,omitempty"`
+	Data       any              `json:"data,omitempty"`
+	Error      string           `json:"error,omitempty"`
+	RetryAfter int              `json:"retry_after,omitempty"`
+}
+
+type wsSubscribeRequest struct {
+	RoomId      int32 `json:"room_id"`
+	LastEventId int64 `json:"last_event_id"` // set when resubscribing, to get the events missed since
+}
+
+type wsChatRequest struct {
+	Message string `json:"message"`
+}
+
+var upgrader = websocket.Upgrader{
+	// the API is open to every origin, like its CORS policy
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// wsConn is the WebSocket of a player, the room events and the replies to the player's requests share it
+type wsConn struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+func (c *wsConn) write(reply wsReply) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	return c.conn.WriteJSON(reply)
+}
+
+func (c *wsConn) writeEvent(event events.SseEvent) error {
+	return c.write(wsReply{Type: wsEvent, Event: &event})
+}
+
+// writeHeartbeat does nothing, the socket is pinged by keepAlive whether it follows a room or not
+func (c *wsConn) writeHeartbeat() error {
+	return nil
+}
+
+// keepAlive pings the client until done is closed. Browsers answer on their own,
+// and every pong pushes the read deadline back, so a client that stops answering is let go
+func (c *wsConn) keepAlive(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// wsSession is what the server keeps of a WebSocket while it is open
+type wsSession struct {
+	hr       *HandlerRepo
+	conn     *wsConn
+	playerId int32
+	addr     string          // where the socket comes from, for rate limits
+	ctx      context.Context // cancelled once the socket is closed
+	runs     chan struct{}   // one token per run going on
+
+	roomId int32              // subscribed room, 0 if none
+	cancel context.CancelFunc // ends the stream of the subscribed room
+	done   chan struct{}      // closed once the stream ended
+}
+
+// WebSocketHandler lets a player follow a room and act in it over a single WebSocket, as an alternative to
+// EventHandler and the submission endpoints. The room events are the ones EventHandler sends
+func (hr *HandlerRepo) WebSocketHandler(w http.ResponseWriter, r *http.Request) {
+	playerId, err := strconv.ParseInt(r.URL.Query().Get("player_id"), 10, 32)
+	if err != nil {
+		http.Error(w, "invalid player_id", http.StatusBadRequest)
+		return
+	}
+	if _, err := hr.queries.GetPlayer(r.Context(), int32(playerId)); err != nil {
+		http.Error(w, "player not found", http.StatusNotFound)
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader replied already
+		hr.logger.Warn("websocket upgrade failed", "player_id", playerId, "error", err)
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(wsMaxMessageSize)
+
+	// a client gets two heartbeats to answer before the socket is considered dead
+	pongWait := 2 * hr.stream.HeartbeatInterval
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	hr.logger.Info("websocket connection established", "player_id", playerId)
+	defer hr.logger.Info("websocket connection closed", "player_id", playerId)
+
+	// runs still queued when the socket closes are dropped, the ones going on are no longer waited for
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	session := &wsSession{
+		hr:       hr,
+		conn:     &wsConn{conn: conn},
+		playerId: int32(playerId),
+		addr:     clientAddr(r),
+		ctx:      ctx,
+		runs:     make(chan struct{}, wsMaxRuns),
+	}
+	defer session.unsubscribe()
+
+	done := make(chan struct{})
+	defer close(done)
+	go session.conn.keepAlive(hr.stream.HeartbeatInterval, done)
+
+	for {
+		// only a failed read ends the socket, a message that isn't a request gets an error back
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var req wsRequest
+		if err := json.Unmarshal(message, &req); err != nil {
+			if err := session.conn.write(wsReply{Type: wsError, Error: "invalid message"}); err != nil {
+				return
+			}
+			continue
+		}
+
+		if req.Type == wsRun {
+			// a run lasts as long as the program, the other requests are served meanwhile
+			select {
+			case session.runs <- struct{}{}:
+			default:
+				if err := session.conn.write(wsReply{Type: wsError, ID: req.ID, Error: errRunsInFlight.Error()}); err != nil {
+					return
+				}
+				continue
+			}
+			go func() {
+				defer func() { <-session.runs }()
+				session.reply(req)
+			}()
+			continue
+		}
+		if err := session.reply(req); err != nil {
+			return
+		}
+	}
+}
+
+// reply runs the request and writes the reply to it
+func (s *wsSession) reply(req wsRequest) error {
+	reply := s.handle(req)
+	reply.ID = req.ID
+	return s.conn.write(reply)
+}
+
+// handle runs a request of the client and returns the reply to it
+func (s *wsSession) handle(req wsRequest) wsReply {
+	switch req.Type {
+	case wsPing:
+		return wsReply{Type: wsPong}
+
+	case wsSubscribe:
+		var data wsSubscribeRequest
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return wsReply{Type: wsError, Error: err.Error()}
+		}
+		if err := s.subscribe(data.RoomId, data.LastEventId); err != nil {
+			return wsReply{Type: wsError, Error: err.Error()}
+		}
+		return wsReply{Type: wsAck}
+
+	case wsUnsubscribe:
+		s.unsubscribe()
+		return wsReply{Type: wsAck}
+
+	case wsSubmit:
+		var data SubmitSolutionRequest
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return wsReply{Type: wsError, Error: err.Error()}
+		}
+		data.PlayerId = s.playerId
+		if data.RoomId == 0 {
+			data.RoomId = s.roomId
+		}
+
+		wait, err := s.hr.submitSolution(s.ctx, data)
+		if err != nil {
+			return errorReply(err, wait)
+		}
+		// the verdict comes as a room event
+		return wsReply{Type: wsAck}
+
+	case wsRun:
+		var data RunCodeRequest
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return wsReply{Type: wsError, Error: err.Error()}
+		}
+		data.PlayerId = s.playerId
+
+		res, wait, err := s.hr.runCode(s.ctx, data, s.addr)
+		if err != nil {
+			return errorReply(err, wait)
+		}
+		return wsReply{Type: wsAck, Data: res}
+
+	case wsChat:
+		var data wsChatRequest
+		if err := json.Unmarshal(req.Data, &data); err != nil {
+			return wsReply{Type: wsError, Error: err.Error()}
+		}
+		if s.roomId == 0 {
+			return wsReply{Type: wsError, Error: errNotSubscribed.Error()}
+		}
+
+		rm, err := s.hr.gr.GetOrLoadRoom(s.ctx, s.roomId)
+		if err != nil {
+			return wsReply{Type: wsError, Error: err.Error()}
+		}
+		if err := rm.Chat(s.playerId, data.Message); err != nil {
+			return wsReply{Type: wsError, Error: err.Error()}
+		}
+		return wsReply{Type: wsAck}
+	}
+
+	return wsReply{Type: wsError, Error: errUnknownMessage.Error()}
+}
+
+// subscribe starts streaming the events of the room, after ending the stream of the previous one
+func (s *wsSession) subscribe(roomId int32, lastEventId int64) error {
+	if err := s.hr.canListen(s.ctx, roomId, s.playerId); err != nil {
+		return err
+	}
+	rm, err := s.hr.gr.GetOrLoadRoom(s.ctx, roomId)
+	if err != nil {
+		return err
+	}
+
+	s.unsubscribe()
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	s.roomId, s.cancel, s.done = roomId, cancel, make(chan struct{})
+
+	go func(done chan struct{}) {
+		defer close(done)
+		s.hr.streamRoom(ctx, rm, s.playerId, lastEventId, s.conn)
+	}(s.done)
+
+	return nil
+}
+
+// unsubscribe ends the stream of the subscribed room and waits for it, so the player leaves the room before anything else
+func (s *wsSession) unsubscribe() {
+	if s.cancel == nil {
+		return
+	}
+
+	s.cancel()
+	<-s.done
+	s.roomId, s.cancel, s.done = 0, nil, nil
+}
+
+// errorReply turns an error of a request into its reply, with the wait of the rate limited ones
+func errorReply(err error, wait time.Duration) wsReply {
+	reply := wsReply{Type: wsError, Error: err.Error()}
+	if wait > 0 {
+		reply.RetryAfter = int(math.Ceil(wait.Seconds()))
+	}
+	return reply
+}
@@ -0,0 +1,160 @@
+package scoring
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Mode selects the scoring strategy of a room
+type Mode string
+
+const (
+	ModeFixed      Mode = "fixed"
+	ModeFirstBlood Mode = "first_blood"
+	ModeTimeDecay  Mode = "time_decay"
+	ModePenalty    Mode = "penalty"
+	ModeICPC       Mode = "icpc"
+)
+
+const (
+	// pointsPerDifficulty is used for questions that have no score of their own
+	pointsPerDifficulty = 50
+
+	// ICPCRejectionPenalty is the penalty time in minutes added for every rejected attempt on a solved question
+	ICPCRejectionPenalty = 20
+)
+
+// Submission is everything a strategy may look at when scoring an accepted (or partially accepted) submission
+type Submission struct {
+	QuestionScore  int32
+	Difficulty     int32
+	ScoreRatio     float64 // share of the question solved, 1 for a full solve
+	SubmittedAt    time.Time
+	MatchStartedAt time.Time
+	WrongAttempts  int32 // rejected submissions of the player on the question so far
+	FirstSolve     bool  // nobody in the room has fully solved the question yet
+}
+
+// basePoints is what the submission is worth before any strategy specific adjustment
+func (s Submission) basePoints() float64 {
+	points := s.QuestionScore
+	if points <= 0 {
+		points = s.Difficulty * pointsPerDifficulty
+	}
+	return float64(points) * s.ScoreRatio
+}
+
+// Strategy turns a judged submission into points
+type Strategy interface {
+	Score(s Submission) int32
+}
+
+// PenaltyStrategy is implemented by strategies that break ties with penalty time, lower is better
+type PenaltyStrategy interface {
+	Penalty(s Submission) int32
+}
+
+// New returns the strategy of a scoring mode with its default settings, an empty mode falls back to ModeFixed
+func New(mode Mode) (Strategy, error) {
+	switch mode {
+	case ModeFixed, "":
+		return Fixed{}, nil
+	case ModeFirstBlood:
+		return FirstBlood{BonusRatio: 0.5}, nil
+	case ModeTimeDecay:
+		return TimeDecay{DecayPerMinute: 0.01, MinRatio: 0.3}, nil
+	case ModePenalty:
+		return Penalty{PenaltyRatio: 0.1, MinRatio: 0.3}, nil
+	case ModeICPC:
+		return ICPC{RejectionPenalty: ICPCRejectionPenalty}, nil
+	default:
+		return nil, fmt.Errorf("unknown scoring mode %q", mode)
+	}
+}
+
+// ParseMode validates a scoring mode, an empty string falls back to ModeFixed
+func ParseMode(s string) (Mode, error) {
+	mode := Mode(s)
+	if mode == "" {
+		mode = ModeFixed
+	}
+	if _, err := New(mode); err != nil {
+		return "", err
+	}
+	return mode, nil
+}
+
+// Fixed awards the question's score
+type Fixed struct{}
+
+func (Fixed) Score(s Submission) int32 {
+	return round(s.basePoints())
+}
+
+// FirstBlood awards the question's score, plus a bonus to the first player who fully solves it
+type FirstBlood struct {
+	BonusRatio float64
+}
+
+func (f FirstBlood) Score(s Submission) int32 {
+	points := s.basePoints()
+	if s.FirstSolve && s.ScoreRatio == 1 {
+		points += points * f.BonusRatio
+	}
+	return round(points)
+}
+
+// TimeDecay lowers the points the longer the match has been going on, never below MinRatio of the score
+type TimeDecay struct {
+	DecayPerMinute float64
+	MinRatio       float64
+}
+
+func (t TimeDecay) Score(s Submission) int32 {
+	elapsed := s.SubmittedAt.Sub(s.MatchStartedAt).Minutes()
+	if elapsed < 0 || s.MatchStartedAt.IsZero() {
+		elapsed = 0
+	}
+	ratio := math.Max(t.MinRatio, 1-elapsed*t.DecayPerMinute)
+	return round(s.basePoints() * ratio)
+}
+
+// Penalty takes PenaltyRatio of the score off for every rejected attempt, never below MinRatio of the score
+type Penalty struct {
+	PenaltyRatio float64
+	MinRatio     float64
+}
+
+func (p Penalty) Score(s Submission) int32 {
+	ratio := math.Max(p.MinRatio, 1-float64(s.WrongAttempts)*p.PenaltyRatio)
+	return round(s.basePoints() * ratio)
+}
+
+// ICPC awards one point per fully solved question, partial solves are worth nothing.
+// Ties are broken by penalty time: minutes from the match start to the solve plus RejectionPenalty per rejected attempt
+type ICPC struct {
+	RejectionPenalty int32
+}
+
+func (ICPC) Score(s Submission) int32 {
+	if s.ScoreRatio < 1 {
+		return 0
+	}
+	return 1
+}
+
+func (i ICPC) Penalty(s Submission) int32 {
+	if s.ScoreRatio < 1 {
+		return 0
+	}
+	var minutes int32
+	if !s.MatchStartedAt.IsZero() && s.SubmittedAt.After(s.MatchStartedAt) {
+		minutes = int32(s.SubmittedAt.Sub(s.MatchStartedAt).Minutes())
+	}
+	return minutes + s.WrongAttempts*i.RejectionPenalty
+}
+
+func round(points float64) int32 {
+	return int32(math.Round(points))
+}
@@ -8,6 +8,13 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type Interactor struct {
+	QuestionID      int32         `json:"question_id"`
+	LanguageID      int32         `json:"language_id"`
+	SourceCode      string        `json:"source_code"`
+	TimeLimitSecond pgtype.Float8 `json:"time_limit_second"`
+}
+
 type Language struct {
 	ID            int32         `json:"id"`
 	Name          string        `json:"name"`
@@ -33,9 +40,40 @@ type Question struct {
 }
 
 type Room struct {
-	ID          int32       `json:"id"`
-	Name        string      `json:"name"`
-	Description pgtype.Text `json:"description"`
+	ID                         int32            `json:"id"`
+	Name                       string           `json:"name"`
+	Description                pgtype.Text      `json:"description"`
+	FeedbackLevel              string           `json:"feedback_level"`
+	ScoringMode                string           `json:"scoring_mode"`
+	StartedAt                  pgtype.Timestamp `json:"started_at"`
+	EndsAt                     pgtype.Timestamp `json:"ends_at"`
+	FreezeMinutes              int32            `json:"freeze_minutes"`
+	Status                     string           `json:"status"`
+	DurationMinutes            int32            `json:"duration_minutes"`
+	OwnerID                    pgtype.Int4      `json:"owner_id"`
+	Visibility                 string           `json:"visibility"`
+	InviteCode                 pgtype.Text      `json:"invite_code"`
+	Password                   pgtype.Text      `json:"-"`
+	MaxPlayers                 int32            `json:"max_players"`
+	AllowedLanguages           []string         `json:"allowed_languages"`
+	MaxSubmissions             int32            `json:"max_submissions"`
+	WrongAnswerCooldownSeconds int32            `json:"wrong_answer_cooldown_seconds"`
+	DrawCount                  int32            `json:"draw_count"`
+	DrawMinDifficulty          int32            `json:"draw_min_difficulty"`
+	DrawMaxDifficulty          int32            `json:"draw_max_difficulty"`
+	DrawTags                   []string         `json:"draw_tags"`
+	StartsAt                   pgtype.Timestamp `json:"starts_at"`
+}
+
+type RoomFreeze struct {
+	RoomID int32  `json:"room_id"`
+	State  []byte `json:"state"`
+	Lifted bool   `json:"lifted"`
+}
+
+type RoomHost struct {
+	RoomID   int32 `json:"room_id"`
+	PlayerID int32 `json:"player_id"`
 }
 
 type RoomPlayer struct {
@@ -46,6 +84,32 @@ type RoomPlayer struct {
 	State    pgtype.Text `json:"state"`
 }
 
+type RoomQuestion struct {
+	RoomID     int32       `json:"room_id"`
+	QuestionID int32       `json:"question_id"`
+	Position   int32       `json:"position"`
+	Points     pgtype.Int4 `json:"points"`
+}
+
+type RoomQuestionScore struct {
+	RoomID        int32            `json:"room_id"`
+	PlayerID      int32            `json:"player_id"`
+	QuestionID    int32            `json:"question_id"`
+	BestScore     int32            `json:"best_score"`
+	UpdatedAt     pgtype.Timestamp `json:"updated_at"`
+	WrongAttempts int32            `json:"wrong_attempts"`
+}
+
+type RoomSolve struct {
+	RoomID        int32            `json:"room_id"`
+	PlayerID      int32            `json:"player_id"`
+	QuestionID    int32            `json:"question_id"`
+	SubmissionID  pgtype.Int4      `json:"submission_id"`
+	SolvedAt      pgtype.Timestamp `json:"solved_at"`
+	AwardedPoints int32            `json:"awarded_points"`
+	Penalty       int32            `json:"penalty"`
+}
+
 type Submission struct {
 	ID                                   int32            `json:"id"`
 	SourceCode                           pgtype.Text      `json:"source_code"`
@@ -88,6 +152,13 @@ type Submission struct {
 	ExecutionHost                        pgtype.Text      `json:"execution_host"`
 }
 
+type Subtask struct {
+	ID         int32  `json:"id"`
+	QuestionID int32  `json:"question_id"`
+	Name       string `json:"name"`
+	Weight     int32  `json:"weight"`
+}
+
 type TestCase struct {
 	ID              int32         `json:"id"`
 	QuestionID      int32         `json:"question_id"`
@@ -95,4 +166,6 @@ type TestCase struct {
 	ExpectedOutput  string        `json:"expected_output"`
 	TimeConstraint  pgtype.Float8 `json:"time_constraint"`
 	SpaceConstraint pgtype.Int4   `json:"space_constraint"`
+	Visibility      string        `json:"visibility"`
+	SubtaskID       pgtype.Int4   `json:"subtask_id"`
 }
@@ -11,6 +11,23 @@ import (
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+const addRoomHost = `-- name: AddRoomHost :exec
+INSERT INTO room_hosts (room_id, player_id)
+VALUES ($1, $2)
+ON CONFLICT (room_id, player_id) DO NOTHING
+`
+
+type AddRoomHostParams struct {
+	RoomID   int32
+	PlayerID int32
+}
+
+// Room Hosts
+func (q *Queries) AddRoomHost(ctx context.Context, arg AddRoomHostParams) error {
+	_, err := q.db.Exec(ctx, addRoomHost, arg.RoomID, arg.PlayerID)
+	return err
+}
+
 const addRoomPlayerScore = `-- name: AddRoomPlayerScore :one
 UPDATE room_players
 SET score = score + $3
@@ -37,6 +54,141 @@ func (q *Queries) AddRoomPlayerScore(ctx context.Context, arg AddRoomPlayerScore
 	return i, err
 }
 
+const addRoomQuestion = `-- name: AddRoomQuestion :exec
+INSERT INTO room_questions (room_id, question_id, position, points)
+VALUES ($1, $2, $3, $4)
+`
+
+type AddRoomQuestionParams struct {
+	RoomID     int32
+	QuestionID int32
+	Position   int32
+	Points     pgtype.Int4
+}
+
+// Room Questions
+// AddRoomQuestion adds a question to the set of a room, a NULL points keeps the question's own score
+func (q *Queries) AddRoomQuestion(ctx context.Context, arg AddRoomQuestionParams) error {
+	_, err := q.db.Exec(ctx, addRoomQuestion,
+		arg.RoomID,
+		arg.QuestionID,
+		arg.Position,
+		arg.Points,
+	)
+	return err
+}
+
+const completeRoomPlayers = `-- name: CompleteRoomPlayers :exec
+UPDATE room_players
+SET state = 'COMPLETED'
+WHERE room_id = $1 AND state = 'PRESENT'
+`
+
+// CompleteRoomPlayers marks the players still in the room once the match is over
+func (q *Queries) CompleteRoomPlayers(ctx context.Context, roomID int32) error {
+	_, err := q.db.Exec(ctx, completeRoomPlayers, roomID)
+	return err
+}
+
+const countRoomPlayers = `-- name: CountRoomPlayers :one
+SELECT COUNT(*) FROM room_players
+WHERE room_id = $1 AND state IS DISTINCT FROM 'LEFT'
+`
+
+// CountRoomPlayers counts the players taking a seat in the room, kicked players free theirs
+func (q *Queries) CountRoomPlayers(ctx context.Context, roomID int32) (int64, error) {
+	row := q.db.QueryRow(ctx, countRoomPlayers, roomID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countRoomQuestionSolvers = `-- name: CountRoomQuestionSolvers :one
+SELECT COUNT(*) FROM room_solves
+WHERE room_id = $1 AND question_id = $2
+`
+
+type CountRoomQuestionSolversParams struct {
+	RoomID     int32
+	QuestionID int32
+}
+
+func (q *Queries) CountRoomQuestionSolvers(ctx context.Context, arg CountRoomQuestionSolversParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countRoomQuestionSolvers, arg.RoomID, arg.QuestionID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countdownRoom = `-- name: CountdownRoom :one
+UPDATE rooms
+SET status = 'countdown', starts_at = $2
+WHERE id = $1 AND status = 'lobby'
+RETURNING id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at
+`
+
+type CountdownRoomParams struct {
+	ID       int32
+	StartsAt pgtype.Timestamp
+}
+
+// CountdownRoom moves a room out of the lobby, starts_at is kept so a restart resumes the countdown
+func (q *Queries) CountdownRoom(ctx context.Context, arg CountdownRoomParams) (Room, error) {
+	row := q.db.QueryRow(ctx, countdownRoom, arg.ID, arg.StartsAt)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.FeedbackLevel,
+		&i.ScoringMode,
+		&i.StartedAt,
+		&i.EndsAt,
+		&i.FreezeMinutes,
+		&i.Status,
+		&i.DurationMinutes,
+		&i.OwnerID,
+		&i.Visibility,
+		&i.InviteCode,
+		&i.Password,
+		&i.MaxPlayers,
+		&i.AllowedLanguages,
+		&i.MaxSubmissions,
+		&i.WrongAnswerCooldownSeconds,
+		&i.DrawCount,
+		&i.DrawMinDifficulty,
+		&i.DrawMaxDifficulty,
+		&i.DrawTags,
+		&i.StartsAt,
+	)
+	return i, err
+}
+
+const createAcceptedSubmission = `-- name: CreateAcceptedSubmission :one
+INSERT INTO submissions (source_code, language_id, message, created_at, finished_at)
+VALUES ($1, $2, $3, $4, now())
+RETURNING id
+`
+
+type CreateAcceptedSubmissionParams struct {
+	SourceCode pgtype.Text
+	LanguageID pgtype.Int4
+	Message    pgtype.Text
+	CreatedAt  pgtype.Timestamp
+}
+
+func (q *Queries) CreateAcceptedSubmission(ctx context.Context, arg CreateAcceptedSubmissionParams) (int32, error) {
+	row := q.db.QueryRow(ctx, createAcceptedSubmission,
+		arg.SourceCode,
+		arg.LanguageID,
+		arg.Message,
+		arg.CreatedAt,
+	)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
 const createLanguage = `-- name: CreateLanguage :one
 INSERT INTO languages (id, name, compile_cmd, run_cmd, timeout_second)
 VALUES ($1, $2, $3, $4, $5)
@@ -132,22 +284,68 @@ func (q *Queries) CreateQuestion(ctx context.Context, arg CreateQuestionParams)
 }
 
 const createRoom = `-- name: CreateRoom :one
-INSERT INTO rooms (id, name, description)
-VALUES ($1, $2, $3)
-RETURNING id, name, description
+INSERT INTO rooms (id, name, description, feedback_level, scoring_mode, duration_minutes, freeze_minutes, owner_id, visibility, invite_code, password, max_players)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+RETURNING id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at
 `
 
 type CreateRoomParams struct {
-	ID          int32
-	Name        string
-	Description pgtype.Text
+	ID              int32
+	Name            string
+	Description     pgtype.Text
+	FeedbackLevel   string
+	ScoringMode     string
+	DurationMinutes int32
+	FreezeMinutes   int32
+	OwnerID         pgtype.Int4
+	Visibility      string
+	InviteCode      pgtype.Text
+	Password        pgtype.Text
+	MaxPlayers      int32
 }
 
 // Rooms
 func (q *Queries) CreateRoom(ctx context.Context, arg CreateRoomParams) (Room, error) {
-	row := q.db.QueryRow(ctx, createRoom, arg.ID, arg.Name, arg.Description)
+	row := q.db.QueryRow(ctx, createRoom,
+		arg.ID,
+		arg.Name,
+		arg.Description,
+		arg.FeedbackLevel,
+		arg.ScoringMode,
+		arg.DurationMinutes,
+		arg.FreezeMinutes,
+		arg.OwnerID,
+		arg.Visibility,
+		arg.InviteCode,
+		arg.Password,
+		arg.MaxPlayers,
+	)
 	var i Room
-	err := row.Scan(&i.ID, &i.Name, &i.Description)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.FeedbackLevel,
+		&i.ScoringMode,
+		&i.StartedAt,
+		&i.EndsAt,
+		&i.FreezeMinutes,
+		&i.Status,
+		&i.DurationMinutes,
+		&i.OwnerID,
+		&i.Visibility,
+		&i.InviteCode,
+		&i.Password,
+		&i.MaxPlayers,
+		&i.AllowedLanguages,
+		&i.MaxSubmissions,
+		&i.WrongAnswerCooldownSeconds,
+		&i.DrawCount,
+		&i.DrawMinDifficulty,
+		&i.DrawMaxDifficulty,
+		&i.DrawTags,
+		&i.StartsAt,
+	)
 	return i, err
 }
 
@@ -183,6 +381,49 @@ func (q *Queries) CreateRoomPlayer(ctx context.Context, arg CreateRoomPlayerPara
 	return i, err
 }
 
+const createRoomSolve = `-- name: CreateRoomSolve :one
+INSERT INTO room_solves (room_id, player_id, question_id, submission_id, solved_at, awarded_points, penalty)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (room_id, player_id, question_id) DO NOTHING
+RETURNING room_id, player_id, question_id, submission_id, solved_at, awarded_points, penalty
+`
+
+type CreateRoomSolveParams struct {
+	RoomID        int32
+	PlayerID      int32
+	QuestionID    int32
+	SubmissionID  pgtype.Int4
+	SolvedAt      pgtype.Timestamp
+	AwardedPoints int32
+	Penalty       int32
+}
+
+// Room Solves
+// The solve ledger, a question is only ever awarded once per player and room.
+// Returns no row if the player already solved the question
+func (q *Queries) CreateRoomSolve(ctx context.Context, arg CreateRoomSolveParams) (RoomSolve, error) {
+	row := q.db.QueryRow(ctx, createRoomSolve,
+		arg.RoomID,
+		arg.PlayerID,
+		arg.QuestionID,
+		arg.SubmissionID,
+		arg.SolvedAt,
+		arg.AwardedPoints,
+		arg.Penalty,
+	)
+	var i RoomSolve
+	err := row.Scan(
+		&i.RoomID,
+		&i.PlayerID,
+		&i.QuestionID,
+		&i.SubmissionID,
+		&i.SolvedAt,
+		&i.AwardedPoints,
+		&i.Penalty,
+	)
+	return i, err
+}
+
 const createSubmission = `-- name: CreateSubmission :one
 INSERT INTO submissions (source_code, language_id, stdin, expected_output, stdout, status_id, created_at, finished_at, time, memory, stderr, token, number_of_runs, cpu_time_limit, cpu_extra_time, wall_time_limit, memory_limit, stack_limit, max_processes_and_or_threads, enable_per_process_and_thread_time_limit, enable_per_process_and_thread_memory_limit, max_file_size, compile_output, exit_code, exit_signal, message, wall_time, compiler_options, command_line_arguments, redirect_stderr_to_stdout, callback_url, additional_files, enable_network, started_at, queued_at, updated_at, queue_host, execution_host)
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38)
@@ -317,10 +558,35 @@ func (q *Queries) CreateSubmission(ctx context.Context, arg CreateSubmissionPara
 	return i, err
 }
 
+const createSubtask = `-- name: CreateSubtask :one
+INSERT INTO subtasks (question_id, name, weight)
+VALUES ($1, $2, $3)
+RETURNING id, question_id, name, weight
+`
+
+type CreateSubtaskParams struct {
+	QuestionID int32
+	Name       string
+	Weight     int32
+}
+
+// Subtasks
+func (q *Queries) CreateSubtask(ctx context.Context, arg CreateSubtaskParams) (Subtask, error) {
+	row := q.db.QueryRow(ctx, createSubtask, arg.QuestionID, arg.Name, arg.Weight)
+	var i Subtask
+	err := row.Scan(
+		&i.ID,
+		&i.QuestionID,
+		&i.Name,
+		&i.Weight,
+	)
+	return i, err
+}
+
 const createTestCase = `-- name: CreateTestCase :one
-INSERT INTO test_cases (question_id, input, expected_output, time_constraint, space_constraint)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, question_id, input, expected_output, time_constraint, space_constraint
+INSERT INTO test_cases (question_id, input, expected_output, time_constraint, space_constraint, visibility, subtask_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING id, question_id, input, expected_output, time_constraint, space_constraint, visibility, subtask_id
 `
 
 type CreateTestCaseParams struct {
@@ -329,6 +595,8 @@ type CreateTestCaseParams struct {
 	ExpectedOutput  string
 	TimeConstraint  pgtype.Float8
 	SpaceConstraint pgtype.Int4
+	Visibility      string
+	SubtaskID       pgtype.Int4
 }
 
 // Test Cases
@@ -339,6 +607,8 @@ func (q *Queries) CreateTestCase(ctx context.Context, arg CreateTestCaseParams)
 		arg.ExpectedOutput,
 		arg.TimeConstraint,
 		arg.SpaceConstraint,
+		arg.Visibility,
+		arg.SubtaskID,
 	)
 	var i TestCase
 	err := row.Scan(
@@ -348,6 +618,8 @@ func (q *Queries) CreateTestCase(ctx context.Context, arg CreateTestCaseParams)
 		&i.ExpectedOutput,
 		&i.TimeConstraint,
 		&i.SpaceConstraint,
+		&i.Visibility,
+		&i.SubtaskID,
 	)
 	return i, err
 }
@@ -412,6 +684,16 @@ func (q *Queries) DeleteRoomPlayer(ctx context.Context, arg DeleteRoomPlayerPara
 	return err
 }
 
+const deleteRoomQuestions = `-- name: DeleteRoomQuestions :exec
+DELETE FROM room_questions
+WHERE room_id = $1
+`
+
+func (q *Queries) DeleteRoomQuestions(ctx context.Context, roomID int32) error {
+	_, err := q.db.Exec(ctx, deleteRoomQuestions, roomID)
+	return err
+}
+
 const deleteSubmission = `-- name: DeleteSubmission :exec
 DELETE FROM submissions
 WHERE id = $1
@@ -422,6 +704,16 @@ func (q *Queries) DeleteSubmission(ctx context.Context, id int32) error {
 	return err
 }
 
+const deleteSubtask = `-- name: DeleteSubtask :exec
+DELETE FROM subtasks
+WHERE id = $1
+`
+
+func (q *Queries) DeleteSubtask(ctx context.Context, id int32) error {
+	_, err := q.db.Exec(ctx, deleteSubtask, id)
+	return err
+}
+
 const deleteTestCase = `-- name: DeleteTestCase :exec
 DELETE FROM test_cases
 WHERE id = $1
@@ -432,6 +724,36 @@ func (q *Queries) DeleteTestCase(ctx context.Context, id int32) error {
 	return err
 }
 
+const disconnectRoomPlayers = `-- name: DisconnectRoomPlayers :exec
+UPDATE room_players
+SET state = 'DISCONNECTED'
+WHERE room_id = $1 AND state = 'PRESENT'
+`
+
+// DisconnectRoomPlayers marks the players of the room as disconnected, their connections did not survive a restart
+func (q *Queries) DisconnectRoomPlayers(ctx context.Context, roomID int32) error {
+	_, err := q.db.Exec(ctx, disconnectRoomPlayers, roomID)
+	return err
+}
+
+const getInteractor = `-- name: GetInteractor :one
+SELECT question_id, language_id, source_code, time_limit_second FROM interactors
+WHERE question_id = $1
+`
+
+// Interactors
+func (q *Queries) GetInteractor(ctx context.Context, questionID int32) (Interactor, error) {
+	row := q.db.QueryRow(ctx, getInteractor, questionID)
+	var i Interactor
+	err := row.Scan(
+		&i.QuestionID,
+		&i.LanguageID,
+		&i.SourceCode,
+		&i.TimeLimitSecond,
+	)
+	return i, err
+}
+
 const getLanguage = `-- name: GetLanguage :one
 SELECT id, name, compile_cmd, run_cmd, timeout_second FROM languages
 WHERE id = $1
@@ -469,17 +791,25 @@ func (q *Queries) GetLanguageByName(ctx context.Context, name string) (Language,
 }
 
 const getLeaderboardForRoom = `-- name: GetLeaderboardForRoom :many
-SELECT p.name, rp.score, rp.place
+SELECT rp.player_id, p.name, rp.score, rp.place, COALESCE(pen.penalty, 0)::integer AS penalty
 FROM room_players rp
 JOIN players p ON rp.player_id = p.id
+LEFT JOIN (
+  SELECT player_id, SUM(penalty) AS penalty
+  FROM room_solves
+  WHERE room_id = $1
+  GROUP BY player_id
+) pen ON pen.player_id = rp.player_id
 WHERE rp.room_id = $1
 ORDER BY rp.place
 `
 
 type GetLeaderboardForRoomRow struct {
-	Name  string
-	Score pgtype.Int4
-	Place pgtype.Int4
+	PlayerID int32
+	Name     string
+	Score    pgtype.Int4
+	Place    pgtype.Int4
+	Penalty  int32
 }
 
 func (q *Queries) GetLeaderboardForRoom(ctx context.Context, roomID int32) ([]GetLeaderboardForRoomRow, error) {
@@ -491,7 +821,13 @@ func (q *Queries) GetLeaderboardForRoom(ctx context.Context, roomID int32) ([]Ge
 	var items []GetLeaderboardForRoomRow
 	for rows.Next() {
 		var i GetLeaderboardForRoomRow
-		if err := rows.Scan(&i.Name, &i.Score, &i.Place); err != nil {
+		if err := rows.Scan(
+			&i.PlayerID,
+			&i.Name,
+			&i.Score,
+			&i.Place,
+			&i.Penalty,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -552,14 +888,124 @@ func (q *Queries) GetQuestion(ctx context.Context, arg GetQuestionParams) (Quest
 }
 
 const getRoom = `-- name: GetRoom :one
-SELECT id, name, description FROM rooms
+SELECT id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at FROM rooms
 WHERE id = $1
 `
 
 func (q *Queries) GetRoom(ctx context.Context, id int32) (Room, error) {
 	row := q.db.QueryRow(ctx, getRoom, id)
 	var i Room
-	err := row.Scan(&i.ID, &i.Name, &i.Description)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.FeedbackLevel,
+		&i.ScoringMode,
+		&i.StartedAt,
+		&i.EndsAt,
+		&i.FreezeMinutes,
+		&i.Status,
+		&i.DurationMinutes,
+		&i.OwnerID,
+		&i.Visibility,
+		&i.InviteCode,
+		&i.Password,
+		&i.MaxPlayers,
+		&i.AllowedLanguages,
+		&i.MaxSubmissions,
+		&i.WrongAnswerCooldownSeconds,
+		&i.DrawCount,
+		&i.DrawMinDifficulty,
+		&i.DrawMaxDifficulty,
+		&i.DrawTags,
+		&i.StartsAt,
+	)
+	return i, err
+}
+
+const getRoomByInviteCode = `-- name: GetRoomByInviteCode :one
+SELECT id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at FROM rooms
+WHERE invite_code = $1
+`
+
+func (q *Queries) GetRoomByInviteCode(ctx context.Context, inviteCode pgtype.Text) (Room, error) {
+	row := q.db.QueryRow(ctx, getRoomByInviteCode, inviteCode)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.FeedbackLevel,
+		&i.ScoringMode,
+		&i.StartedAt,
+		&i.EndsAt,
+		&i.FreezeMinutes,
+		&i.Status,
+		&i.DurationMinutes,
+		&i.OwnerID,
+		&i.Visibility,
+		&i.InviteCode,
+		&i.Password,
+		&i.MaxPlayers,
+		&i.AllowedLanguages,
+		&i.MaxSubmissions,
+		&i.WrongAnswerCooldownSeconds,
+		&i.DrawCount,
+		&i.DrawMinDifficulty,
+		&i.DrawMaxDifficulty,
+		&i.DrawTags,
+		&i.StartsAt,
+	)
+	return i, err
+}
+
+const getRoomForUpdate = `-- name: GetRoomForUpdate :one
+SELECT id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at FROM rooms
+WHERE id = $1
+FOR UPDATE
+`
+
+// Locks the room until the end of the transaction, state transitions wait for it
+func (q *Queries) GetRoomForUpdate(ctx context.Context, id int32) (Room, error) {
+	row := q.db.QueryRow(ctx, getRoomForUpdate, id)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.FeedbackLevel,
+		&i.ScoringMode,
+		&i.StartedAt,
+		&i.EndsAt,
+		&i.FreezeMinutes,
+		&i.Status,
+		&i.DurationMinutes,
+		&i.OwnerID,
+		&i.Visibility,
+		&i.InviteCode,
+		&i.Password,
+		&i.MaxPlayers,
+		&i.AllowedLanguages,
+		&i.MaxSubmissions,
+		&i.WrongAnswerCooldownSeconds,
+		&i.DrawCount,
+		&i.DrawMinDifficulty,
+		&i.DrawMaxDifficulty,
+		&i.DrawTags,
+		&i.StartsAt,
+	)
+	return i, err
+}
+
+const getRoomFreeze = `-- name: GetRoomFreeze :one
+SELECT room_id, state, lifted FROM room_freezes
+WHERE room_id = $1
+`
+
+func (q *Queries) GetRoomFreeze(ctx context.Context, roomID int32) (RoomFreeze, error) {
+	row := q.db.QueryRow(ctx, getRoomFreeze, roomID)
+	var i RoomFreeze
+	err := row.Scan(&i.RoomID, &i.State, &i.Lifted)
 	return i, err
 }
 
@@ -619,6 +1065,79 @@ func (q *Queries) GetRoomPlayers(ctx context.Context, roomID int32) ([]RoomPlaye
 	return items, nil
 }
 
+const getRoomQuestion = `-- name: GetRoomQuestion :one
+SELECT room_id, question_id, position, points FROM room_questions
+WHERE room_id = $1 AND question_id = $2
+`
+
+type GetRoomQuestionParams struct {
+	RoomID     int32
+	QuestionID int32
+}
+
+func (q *Queries) GetRoomQuestion(ctx context.Context, arg GetRoomQuestionParams) (RoomQuestion, error) {
+	row := q.db.QueryRow(ctx, getRoomQuestion, arg.RoomID, arg.QuestionID)
+	var i RoomQuestion
+	err := row.Scan(
+		&i.RoomID,
+		&i.QuestionID,
+		&i.Position,
+		&i.Points,
+	)
+	return i, err
+}
+
+const getRoomQuestionScore = `-- name: GetRoomQuestionScore :one
+SELECT room_id, player_id, question_id, best_score, updated_at, wrong_attempts FROM room_question_scores
+WHERE room_id = $1 AND player_id = $2 AND question_id = $3
+`
+
+type GetRoomQuestionScoreParams struct {
+	RoomID     int32
+	PlayerID   int32
+	QuestionID int32
+}
+
+func (q *Queries) GetRoomQuestionScore(ctx context.Context, arg GetRoomQuestionScoreParams) (RoomQuestionScore, error) {
+	row := q.db.QueryRow(ctx, getRoomQuestionScore, arg.RoomID, arg.PlayerID, arg.QuestionID)
+	var i RoomQuestionScore
+	err := row.Scan(
+		&i.RoomID,
+		&i.PlayerID,
+		&i.QuestionID,
+		&i.BestScore,
+		&i.UpdatedAt,
+		&i.WrongAttempts,
+	)
+	return i, err
+}
+
+const getRoomSolve = `-- name: GetRoomSolve :one
+SELECT room_id, player_id, question_id, submission_id, solved_at, awarded_points, penalty FROM room_solves
+WHERE room_id = $1 AND player_id = $2 AND question_id = $3
+`
+
+type GetRoomSolveParams struct {
+	RoomID     int32
+	PlayerID   int32
+	QuestionID int32
+}
+
+func (q *Queries) GetRoomSolve(ctx context.Context, arg GetRoomSolveParams) (RoomSolve, error) {
+	row := q.db.QueryRow(ctx, getRoomSolve, arg.RoomID, arg.PlayerID, arg.QuestionID)
+	var i RoomSolve
+	err := row.Scan(
+		&i.RoomID,
+		&i.PlayerID,
+		&i.QuestionID,
+		&i.SubmissionID,
+		&i.SolvedAt,
+		&i.AwardedPoints,
+		&i.Penalty,
+	)
+	return i, err
+}
+
 const getSubmission = `-- name: GetSubmission :one
 SELECT id, source_code, language_id, stdin, expected_output, stdout, status_id, created_at, finished_at, time, memory, stderr, token, number_of_runs, cpu_time_limit, cpu_extra_time, wall_time_limit, memory_limit, stack_limit, max_processes_and_or_threads, enable_per_process_and_thread_time_limit, enable_per_process_and_thread_memory_limit, max_file_size, compile_output, exit_code, exit_signal, message, wall_time, compiler_options, command_line_arguments, redirect_stderr_to_stdout, callback_url, additional_files, enable_network, started_at, queued_at, updated_at, queue_host, execution_host FROM submissions
 WHERE id = $1
@@ -672,7 +1191,7 @@ func (q *Queries) GetSubmission(ctx context.Context, id int32) (Submission, erro
 }
 
 const getTestCase = `-- name: GetTestCase :one
-SELECT id, question_id, input, expected_output, time_constraint, space_constraint FROM test_cases
+SELECT id, question_id, input, expected_output, time_constraint, space_constraint, visibility, subtask_id FROM test_cases
 WHERE id = $1
 `
 
@@ -686,30 +1205,73 @@ func (q *Queries) GetTestCase(ctx context.Context, id int32) (TestCase, error) {
 		&i.ExpectedOutput,
 		&i.TimeConstraint,
 		&i.SpaceConstraint,
+		&i.Visibility,
+		&i.SubtaskID,
 	)
 	return i, err
 }
 
-const listLanguages = `-- name: ListLanguages :many
-SELECT id, name, compile_cmd, run_cmd, timeout_second FROM languages
+const isRoomHost = `-- name: IsRoomHost :one
+SELECT EXISTS (
+  SELECT 1 FROM rooms r WHERE r.id = $1 AND r.owner_id = $2
+) OR EXISTS (
+  SELECT 1 FROM room_hosts rh WHERE rh.room_id = $1 AND rh.player_id = $2
+) AS is_host
+`
+
+type IsRoomHostParams struct {
+	RoomID   int32
+	PlayerID int32
+}
+
+// IsRoomHost reports whether the player owns the room or is one of its co-hosts
+func (q *Queries) IsRoomHost(ctx context.Context, arg IsRoomHostParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isRoomHost, arg.RoomID, arg.PlayerID)
+	var is_host bool
+	err := row.Scan(&is_host)
+	return is_host, err
+}
+
+const listActiveRooms = `-- name: ListActiveRooms :many
+SELECT id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at FROM rooms
+WHERE status <> 'archived'
 ORDER BY id
 `
 
-func (q *Queries) ListLanguages(ctx context.Context) ([]Language, error) {
-	rows, err := q.db.Query(ctx, listLanguages)
+// ListActiveRooms returns the rooms that need a manager, archived rooms are only kept for their results
+func (q *Queries) ListActiveRooms(ctx context.Context) ([]Room, error) {
+	rows, err := q.db.Query(ctx, listActiveRooms)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Language
+	var items []Room
 	for rows.Next() {
-		var i Language
+		var i Room
 		if err := rows.Scan(
 			&i.ID,
 			&i.Name,
-			&i.CompileCmd,
-			&i.RunCmd,
-			&i.TimeoutSecond,
+			&i.Description,
+			&i.FeedbackLevel,
+			&i.ScoringMode,
+			&i.StartedAt,
+			&i.EndsAt,
+			&i.FreezeMinutes,
+			&i.Status,
+			&i.DurationMinutes,
+			&i.OwnerID,
+			&i.Visibility,
+			&i.InviteCode,
+			&i.Password,
+			&i.MaxPlayers,
+			&i.AllowedLanguages,
+			&i.MaxSubmissions,
+			&i.WrongAnswerCooldownSeconds,
+			&i.DrawCount,
+			&i.DrawMinDifficulty,
+			&i.DrawMaxDifficulty,
+			&i.DrawTags,
+			&i.StartsAt,
 		); err != nil {
 			return nil, err
 		}
@@ -721,21 +1283,52 @@ func (q *Queries) ListLanguages(ctx context.Context) ([]Language, error) {
 	return items, nil
 }
 
-const listPlayers = `-- name: ListPlayers :many
-SELECT id, name, password FROM players
-ORDER BY id
+const listDrawCandidates = `-- name: ListDrawCandidates :many
+SELECT DISTINCT ON (q.id) q.id, q.difficulty
+FROM questions q
+WHERE q.difficulty >= $1
+  AND ($2 = 0 OR q.difficulty <= $2)
+  AND (cardinality($3::text[]) = 0 OR EXISTS (
+    SELECT 1 FROM question_tags qt
+    WHERE qt.question_id = q.id AND qt.tag = ANY($3::text[])
+  ))
+  AND NOT EXISTS (
+    SELECT 1 FROM room_solves rs
+    JOIN room_players rp ON rp.player_id = rs.player_id
+    WHERE rp.room_id = $4 AND rp.state IS DISTINCT FROM 'LEFT' AND rs.question_id = q.id
+  )
+ORDER BY q.id
 `
 
-func (q *Queries) ListPlayers(ctx context.Context) ([]Player, error) {
-	rows, err := q.db.Query(ctx, listPlayers)
+type ListDrawCandidatesRow struct {
+	ID         int32
+	Difficulty int32
+}
+
+type ListDrawCandidatesParams struct {
+	MinDifficulty int32
+	MaxDifficulty int32
+	Tags          []string
+	RoomID        int32
+}
+
+// ListDrawCandidates returns the questions a room can draw from: in the difficulty range, with one of the tags if any
+// are given, and never solved before by one of the room's players. A max difficulty of 0 has no upper bound
+func (q *Queries) ListDrawCandidates(ctx context.Context, arg ListDrawCandidatesParams) ([]ListDrawCandidatesRow, error) {
+	rows, err := q.db.Query(ctx, listDrawCandidates,
+		arg.MinDifficulty,
+		arg.MaxDifficulty,
+		arg.Tags,
+		arg.RoomID,
+	)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Player
+	var items []ListDrawCandidatesRow
 	for rows.Next() {
-		var i Player
-		if err := rows.Scan(&i.ID, &i.Name, &i.Password); err != nil {
+		var i ListDrawCandidatesRow
+		if err := rows.Scan(&i.ID, &i.Difficulty); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -746,7 +1339,63 @@ func (q *Queries) ListPlayers(ctx context.Context) ([]Player, error) {
 	return items, nil
 }
 
-const listPlayersInRoom = `-- name: ListPlayersInRoom :many
+const listLanguages = `-- name: ListLanguages :many
+SELECT id, name, compile_cmd, run_cmd, timeout_second FROM languages
+ORDER BY id
+`
+
+func (q *Queries) ListLanguages(ctx context.Context) ([]Language, error) {
+	rows, err := q.db.Query(ctx, listLanguages)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Language
+	for rows.Next() {
+		var i Language
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.CompileCmd,
+			&i.RunCmd,
+			&i.TimeoutSecond,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPlayers = `-- name: ListPlayers :many
+SELECT id, name, password FROM players
+ORDER BY id
+`
+
+func (q *Queries) ListPlayers(ctx context.Context) ([]Player, error) {
+	rows, err := q.db.Query(ctx, listPlayers)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Player
+	for rows.Next() {
+		var i Player
+		if err := rows.Scan(&i.ID, &i.Name, &i.Password); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPlayersInRoom = `-- name: ListPlayersInRoom :many
 SELECT p.id, p.name, p.password FROM players p
 JOIN room_players rp ON p.id = rp.player_id
 WHERE rp.room_id = $1
@@ -773,6 +1422,56 @@ func (q *Queries) ListPlayersInRoom(ctx context.Context, roomID int32) ([]Player
 	return items, nil
 }
 
+const listPublicRooms = `-- name: ListPublicRooms :many
+SELECT id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at FROM rooms
+WHERE visibility = 'public'
+ORDER BY id
+`
+
+func (q *Queries) ListPublicRooms(ctx context.Context) ([]Room, error) {
+	rows, err := q.db.Query(ctx, listPublicRooms)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Room
+	for rows.Next() {
+		var i Room
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.FeedbackLevel,
+			&i.ScoringMode,
+			&i.StartedAt,
+			&i.EndsAt,
+			&i.FreezeMinutes,
+			&i.Status,
+			&i.DurationMinutes,
+			&i.OwnerID,
+			&i.Visibility,
+			&i.InviteCode,
+			&i.Password,
+			&i.MaxPlayers,
+			&i.AllowedLanguages,
+			&i.MaxSubmissions,
+			&i.WrongAnswerCooldownSeconds,
+			&i.DrawCount,
+			&i.DrawMinDifficulty,
+			&i.DrawMaxDifficulty,
+			&i.DrawTags,
+			&i.StartsAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listQuestions = `-- name: ListQuestions :many
 SELECT id, language_id, template_function, title, description, score, difficulty FROM questions
 ORDER BY id, language_id
@@ -840,8 +1539,116 @@ func (q *Queries) ListQuestionsByLanguage(ctx context.Context, languageID int32)
 	return items, nil
 }
 
+const listRoomHosts = `-- name: ListRoomHosts :many
+SELECT room_id, player_id FROM room_hosts
+WHERE room_id = $1
+ORDER BY player_id
+`
+
+func (q *Queries) ListRoomHosts(ctx context.Context, roomID int32) ([]RoomHost, error) {
+	rows, err := q.db.Query(ctx, listRoomHosts, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RoomHost
+	for rows.Next() {
+		var i RoomHost
+		if err := rows.Scan(&i.RoomID, &i.PlayerID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRoomQuestions = `-- name: ListRoomQuestions :many
+SELECT rq.question_id, rq.position, COALESCE(rq.points, q.score)::integer AS points, q.title, q.description, q.difficulty
+FROM room_questions rq
+JOIN (
+  SELECT DISTINCT ON (id) id, title, description, score, difficulty
+  FROM questions
+  ORDER BY id, language_id
+) q ON q.id = rq.question_id
+WHERE rq.room_id = $1
+ORDER BY rq.position
+`
+
+type ListRoomQuestionsRow struct {
+	QuestionID  int32
+	Position    int32
+	Points      int32
+	Title       string
+	Description pgtype.Text
+	Difficulty  int32
+}
+
+// ListRoomQuestions returns the question set of a room in order, questions exist once per language so only one row of each is kept
+func (q *Queries) ListRoomQuestions(ctx context.Context, roomID int32) ([]ListRoomQuestionsRow, error) {
+	rows, err := q.db.Query(ctx, listRoomQuestions, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListRoomQuestionsRow
+	for rows.Next() {
+		var i ListRoomQuestionsRow
+		if err := rows.Scan(
+			&i.QuestionID,
+			&i.Position,
+			&i.Points,
+			&i.Title,
+			&i.Description,
+			&i.Difficulty,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRoomQuestionScores = `-- name: ListRoomQuestionScores :many
+SELECT room_id, player_id, question_id, best_score, updated_at, wrong_attempts FROM room_question_scores
+WHERE room_id = $1
+ORDER BY player_id, question_id
+`
+
+func (q *Queries) ListRoomQuestionScores(ctx context.Context, roomID int32) ([]RoomQuestionScore, error) {
+	rows, err := q.db.Query(ctx, listRoomQuestionScores, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RoomQuestionScore
+	for rows.Next() {
+		var i RoomQuestionScore
+		if err := rows.Scan(
+			&i.RoomID,
+			&i.PlayerID,
+			&i.QuestionID,
+			&i.BestScore,
+			&i.UpdatedAt,
+			&i.WrongAttempts,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listRooms = `-- name: ListRooms :many
-SELECT id, name, description FROM rooms
+SELECT id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at FROM rooms
 ORDER BY id
 `
 
@@ -854,7 +1661,100 @@ func (q *Queries) ListRooms(ctx context.Context) ([]Room, error) {
 	var items []Room
 	for rows.Next() {
 		var i Room
-		if err := rows.Scan(&i.ID, &i.Name, &i.Description); err != nil {
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.FeedbackLevel,
+			&i.ScoringMode,
+			&i.StartedAt,
+			&i.EndsAt,
+			&i.FreezeMinutes,
+			&i.Status,
+			&i.DurationMinutes,
+			&i.OwnerID,
+			&i.Visibility,
+			&i.InviteCode,
+			&i.Password,
+			&i.MaxPlayers,
+			&i.AllowedLanguages,
+			&i.MaxSubmissions,
+			&i.WrongAnswerCooldownSeconds,
+			&i.DrawCount,
+			&i.DrawMinDifficulty,
+			&i.DrawMaxDifficulty,
+			&i.DrawTags,
+			&i.StartsAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRoomSolves = `-- name: ListRoomSolves :many
+SELECT room_id, player_id, question_id, submission_id, solved_at, awarded_points, penalty FROM room_solves
+WHERE room_id = $1
+ORDER BY solved_at
+`
+
+func (q *Queries) ListRoomSolves(ctx context.Context, roomID int32) ([]RoomSolve, error) {
+	rows, err := q.db.Query(ctx, listRoomSolves, roomID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []RoomSolve
+	for rows.Next() {
+		var i RoomSolve
+		if err := rows.Scan(
+			&i.RoomID,
+			&i.PlayerID,
+			&i.QuestionID,
+			&i.SubmissionID,
+			&i.SolvedAt,
+			&i.AwardedPoints,
+			&i.Penalty,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSampleTestCasesForQuestion = `-- name: ListSampleTestCasesForQuestion :many
+SELECT id, question_id, input, expected_output, time_constraint, space_constraint, visibility, subtask_id FROM test_cases
+WHERE question_id = $1 AND visibility = 'sample'
+ORDER BY id
+`
+
+func (q *Queries) ListSampleTestCasesForQuestion(ctx context.Context, questionID int32) ([]TestCase, error) {
+	rows, err := q.db.Query(ctx, listSampleTestCasesForQuestion, questionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []TestCase
+	for rows.Next() {
+		var i TestCase
+		if err := rows.Scan(
+			&i.ID,
+			&i.QuestionID,
+			&i.Input,
+			&i.ExpectedOutput,
+			&i.TimeConstraint,
+			&i.SpaceConstraint,
+			&i.Visibility,
+			&i.SubtaskID,
+		); err != nil {
 			return nil, err
 		}
 		items = append(items, i)
@@ -930,12 +1830,43 @@ func (q *Queries) ListSubmissions(ctx context.Context) ([]Submission, error) {
 	return items, nil
 }
 
-const listTestCasesForQuestion = `-- name: ListTestCasesForQuestion :many
-SELECT id, question_id, input, expected_output, time_constraint, space_constraint FROM test_cases
+const listSubtasksForQuestion = `-- name: ListSubtasksForQuestion :many
+SELECT id, question_id, name, weight FROM subtasks
 WHERE question_id = $1
 ORDER BY id
 `
 
+func (q *Queries) ListSubtasksForQuestion(ctx context.Context, questionID int32) ([]Subtask, error) {
+	rows, err := q.db.Query(ctx, listSubtasksForQuestion, questionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Subtask
+	for rows.Next() {
+		var i Subtask
+		if err := rows.Scan(
+			&i.ID,
+			&i.QuestionID,
+			&i.Name,
+			&i.Weight,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTestCasesForQuestion = `-- name: ListTestCasesForQuestion :many
+SELECT id, question_id, input, expected_output, time_constraint, space_constraint, visibility, subtask_id FROM test_cases
+WHERE question_id = $1
+ORDER BY visibility = 'hidden', id
+`
+
 func (q *Queries) ListTestCasesForQuestion(ctx context.Context, questionID int32) ([]TestCase, error) {
 	rows, err := q.db.Query(ctx, listTestCasesForQuestion, questionID)
 	if err != nil {
@@ -952,6 +1883,8 @@ func (q *Queries) ListTestCasesForQuestion(ctx context.Context, questionID int32
 			&i.ExpectedOutput,
 			&i.TimeConstraint,
 			&i.SpaceConstraint,
+			&i.Visibility,
+			&i.SubtaskID,
 		); err != nil {
 			return nil, err
 		}
@@ -963,6 +1896,140 @@ func (q *Queries) ListTestCasesForQuestion(ctx context.Context, questionID int32
 	return items, nil
 }
 
+const recordRoomQuestionWrongAttempt = `-- name: RecordRoomQuestionWrongAttempt :exec
+INSERT INTO room_question_scores (room_id, player_id, question_id, wrong_attempts)
+VALUES ($1, $2, $3, 1)
+ON CONFLICT (room_id, player_id, question_id) DO UPDATE
+SET wrong_attempts = room_question_scores.wrong_attempts + 1
+WHERE NOT EXISTS (
+  SELECT 1 FROM room_solves rs
+  WHERE rs.room_id = $1 AND rs.player_id = $2 AND rs.question_id = $3
+)
+`
+
+type RecordRoomQuestionWrongAttemptParams struct {
+	RoomID     int32
+	PlayerID   int32
+	QuestionID int32
+}
+
+// RecordRoomQuestionWrongAttempt counts a rejected submission, attempts after the question is solved are ignored
+func (q *Queries) RecordRoomQuestionWrongAttempt(ctx context.Context, arg RecordRoomQuestionWrongAttemptParams) error {
+	_, err := q.db.Exec(ctx, recordRoomQuestionWrongAttempt, arg.RoomID, arg.PlayerID, arg.QuestionID)
+	return err
+}
+
+const refreshRoomPlayerScore = `-- name: RefreshRoomPlayerScore :one
+UPDATE room_players
+SET score = (
+  SELECT COALESCE(SUM(COALESCE(rs.awarded_points, rqs.best_score)), 0)::integer
+  FROM room_question_scores rqs
+  LEFT JOIN room_solves rs
+    ON rs.room_id = rqs.room_id AND rs.player_id = rqs.player_id AND rs.question_id = rqs.question_id
+  WHERE rqs.room_id = $1 AND rqs.player_id = $2
+)
+WHERE room_id = $1 AND player_id = $2
+RETURNING room_id, player_id, score, place, state
+`
+
+type RefreshRoomPlayerScoreParams struct {
+	RoomID   int32
+	PlayerID int32
+}
+
+// RefreshRoomPlayerScore sets the room score of a player from the solve ledger,
+// falling back to the best partial score of the questions they have not solved
+func (q *Queries) RefreshRoomPlayerScore(ctx context.Context, arg RefreshRoomPlayerScoreParams) (RoomPlayer, error) {
+	row := q.db.QueryRow(ctx, refreshRoomPlayerScore, arg.RoomID, arg.PlayerID)
+	var i RoomPlayer
+	err := row.Scan(
+		&i.RoomID,
+		&i.PlayerID,
+		&i.Score,
+		&i.Place,
+		&i.State,
+	)
+	return i, err
+}
+
+const removeRoomHost = `-- name: RemoveRoomHost :exec
+DELETE FROM room_hosts
+WHERE room_id = $1 AND player_id = $2
+`
+
+type RemoveRoomHostParams struct {
+	RoomID   int32
+	PlayerID int32
+}
+
+func (q *Queries) RemoveRoomHost(ctx context.Context, arg RemoveRoomHostParams) error {
+	_, err := q.db.Exec(ctx, removeRoomHost, arg.RoomID, arg.PlayerID)
+	return err
+}
+
+const saveRoomFreeze = `-- name: SaveRoomFreeze :exec
+INSERT INTO room_freezes (room_id, state, lifted)
+VALUES ($1, $2, $3)
+ON CONFLICT (room_id) DO UPDATE SET state = EXCLUDED.state, lifted = EXCLUDED.lifted
+`
+
+type SaveRoomFreezeParams struct {
+	RoomID int32
+	State  []byte
+	Lifted bool
+}
+
+// Scoreboard Freezes
+// The freeze of a room outlives its manager, so a restart or an eviction can't lose the results kept back
+func (q *Queries) SaveRoomFreeze(ctx context.Context, arg SaveRoomFreezeParams) error {
+	_, err := q.db.Exec(ctx, saveRoomFreeze, arg.RoomID, arg.State, arg.Lifted)
+	return err
+}
+
+const startRoom = `-- name: StartRoom :one
+UPDATE rooms
+SET status = 'running', started_at = $2, ends_at = $3
+WHERE id = $1 AND status = 'countdown'
+RETURNING id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at
+`
+
+type StartRoomParams struct {
+	ID        int32
+	StartedAt pgtype.Timestamp
+	EndsAt    pgtype.Timestamp
+}
+
+func (q *Queries) StartRoom(ctx context.Context, arg StartRoomParams) (Room, error) {
+	row := q.db.QueryRow(ctx, startRoom, arg.ID, arg.StartedAt, arg.EndsAt)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.FeedbackLevel,
+		&i.ScoringMode,
+		&i.StartedAt,
+		&i.EndsAt,
+		&i.FreezeMinutes,
+		&i.Status,
+		&i.DurationMinutes,
+		&i.OwnerID,
+		&i.Visibility,
+		&i.InviteCode,
+		&i.Password,
+		&i.MaxPlayers,
+		&i.AllowedLanguages,
+		&i.MaxSubmissions,
+		&i.WrongAnswerCooldownSeconds,
+		&i.DrawCount,
+		&i.DrawMinDifficulty,
+		&i.DrawMaxDifficulty,
+		&i.DrawTags,
+		&i.StartsAt,
+	)
+	return i, err
+}
+
 const updateLanguage = `-- name: UpdateLanguage :one
 UPDATE languages
 SET name = $2, compile_cmd = $3, run_cmd = $4, timeout_second = $5
@@ -1059,31 +2126,139 @@ func (q *Queries) UpdateQuestion(ctx context.Context, arg UpdateQuestionParams)
 
 const updateRoom = `-- name: UpdateRoom :one
 UPDATE rooms
-SET name = $2, description = $3
+SET name = $2,
+    description = $3,
+    allowed_languages = $4,
+    scoring_mode = $5,
+    duration_minutes = $6,
+    feedback_level = $7,
+    max_submissions = $8,
+    wrong_answer_cooldown_seconds = $9
 WHERE id = $1
-RETURNING id, name, description
+RETURNING id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at
 `
 
 type UpdateRoomParams struct {
-	ID          int32
-	Name        string
-	Description pgtype.Text
+	ID                         int32
+	Name                       string
+	Description                pgtype.Text
+	AllowedLanguages           []string
+	ScoringMode                string
+	DurationMinutes            int32
+	FeedbackLevel              string
+	MaxSubmissions             int32
+	WrongAnswerCooldownSeconds int32
 }
 
+// UpdateRoom saves the name, description and settings of a room
 func (q *Queries) UpdateRoom(ctx context.Context, arg UpdateRoomParams) (Room, error) {
-	row := q.db.QueryRow(ctx, updateRoom, arg.ID, arg.Name, arg.Description)
+	row := q.db.QueryRow(ctx, updateRoom,
+		arg.ID,
+		arg.Name,
+		arg.Description,
+		arg.AllowedLanguages,
+		arg.ScoringMode,
+		arg.DurationMinutes,
+		arg.FeedbackLevel,
+		arg.MaxSubmissions,
+		arg.WrongAnswerCooldownSeconds,
+	)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.FeedbackLevel,
+		&i.ScoringMode,
+		&i.StartedAt,
+		&i.EndsAt,
+		&i.FreezeMinutes,
+		&i.Status,
+		&i.DurationMinutes,
+		&i.OwnerID,
+		&i.Visibility,
+		&i.InviteCode,
+		&i.Password,
+		&i.MaxPlayers,
+		&i.AllowedLanguages,
+		&i.MaxSubmissions,
+		&i.WrongAnswerCooldownSeconds,
+		&i.DrawCount,
+		&i.DrawMinDifficulty,
+		&i.DrawMaxDifficulty,
+		&i.DrawTags,
+		&i.StartsAt,
+	)
+	return i, err
+}
+
+const updateRoomDraw = `-- name: UpdateRoomDraw :one
+UPDATE rooms
+SET draw_count = $2, draw_min_difficulty = $3, draw_max_difficulty = $4, draw_tags = $5
+WHERE id = $1
+RETURNING id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at
+`
+
+type UpdateRoomDrawParams struct {
+	ID                int32
+	DrawCount         int32
+	DrawMinDifficulty int32
+	DrawMaxDifficulty int32
+	DrawTags          []string
+}
+
+// UpdateRoomDraw saves how the questions of the room are drawn when the match starts
+func (q *Queries) UpdateRoomDraw(ctx context.Context, arg UpdateRoomDrawParams) (Room, error) {
+	row := q.db.QueryRow(ctx, updateRoomDraw,
+		arg.ID,
+		arg.DrawCount,
+		arg.DrawMinDifficulty,
+		arg.DrawMaxDifficulty,
+		arg.DrawTags,
+	)
 	var i Room
-	err := row.Scan(&i.ID, &i.Name, &i.Description)
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.FeedbackLevel,
+		&i.ScoringMode,
+		&i.StartedAt,
+		&i.EndsAt,
+		&i.FreezeMinutes,
+		&i.Status,
+		&i.DurationMinutes,
+		&i.OwnerID,
+		&i.Visibility,
+		&i.InviteCode,
+		&i.Password,
+		&i.MaxPlayers,
+		&i.AllowedLanguages,
+		&i.MaxSubmissions,
+		&i.WrongAnswerCooldownSeconds,
+		&i.DrawCount,
+		&i.DrawMinDifficulty,
+		&i.DrawMaxDifficulty,
+		&i.DrawTags,
+		&i.StartsAt,
+	)
 	return i, err
 }
 
 const updateRoomPlayerRanks = `-- name: UpdateRoomPlayerRanks :exec
-WITH ranked_players AS (
-  SELECT
-    player_id,
-    RANK() OVER (ORDER BY score DESC) as new_place
-  FROM room_players
+WITH penalties AS (
+  SELECT player_id, SUM(penalty) AS penalty
+  FROM room_solves
   WHERE room_id = $1
+  GROUP BY player_id
+),
+ranked_players AS (
+  SELECT
+    rp.player_id,
+    RANK() OVER (ORDER BY rp.score DESC, COALESCE(pen.penalty, 0)) as new_place
+  FROM room_players rp
+  LEFT JOIN penalties pen ON pen.player_id = rp.player_id
+  WHERE rp.room_id = $1
 )
 UPDATE room_players rp
 SET place = rp_ranked.new_place
@@ -1091,6 +2266,8 @@ FROM ranked_players rp_ranked
 WHERE rp.room_id = $1 AND rp.player_id = rp_ranked.player_id
 `
 
+// UpdateRoomPlayerRanks ranks by score, ties are broken by the penalty time of the solve ledger,
+// which is only ever non-zero in ICPC rooms
 func (q *Queries) UpdateRoomPlayerRanks(ctx context.Context, roomID int32) error {
 	_, err := q.db.Exec(ctx, updateRoomPlayerRanks, roomID)
 	return err
@@ -1128,6 +2305,68 @@ func (q *Queries) UpdateRoomPlayerScore(ctx context.Context, arg UpdateRoomPlaye
 	return i, err
 }
 
+const updateRoomPlayerState = `-- name: UpdateRoomPlayerState :exec
+UPDATE room_players
+SET state = $3
+WHERE room_id = $1 AND player_id = $2
+`
+
+type UpdateRoomPlayerStateParams struct {
+	RoomID   int32
+	PlayerID int32
+	State    pgtype.Text
+}
+
+func (q *Queries) UpdateRoomPlayerState(ctx context.Context, arg UpdateRoomPlayerStateParams) error {
+	_, err := q.db.Exec(ctx, updateRoomPlayerState, arg.RoomID, arg.PlayerID, arg.State)
+	return err
+}
+
+const updateRoomStatus = `-- name: UpdateRoomStatus :one
+UPDATE rooms
+SET status = $1
+WHERE id = $2 AND status = $3
+RETURNING id, name, description, feedback_level, scoring_mode, started_at, ends_at, freeze_minutes, status, duration_minutes, owner_id, visibility, invite_code, password, max_players, allowed_languages, max_submissions, wrong_answer_cooldown_seconds, draw_count, draw_min_difficulty, draw_max_difficulty, draw_tags, starts_at
+`
+
+type UpdateRoomStatusParams struct {
+	NewStatus string
+	ID        int32
+	OldStatus string
+}
+
+// UpdateRoomStatus moves a room from one state to the next, no row is returned if the room is not in old_status
+func (q *Queries) UpdateRoomStatus(ctx context.Context, arg UpdateRoomStatusParams) (Room, error) {
+	row := q.db.QueryRow(ctx, updateRoomStatus, arg.NewStatus, arg.ID, arg.OldStatus)
+	var i Room
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Description,
+		&i.FeedbackLevel,
+		&i.ScoringMode,
+		&i.StartedAt,
+		&i.EndsAt,
+		&i.FreezeMinutes,
+		&i.Status,
+		&i.DurationMinutes,
+		&i.OwnerID,
+		&i.Visibility,
+		&i.InviteCode,
+		&i.Password,
+		&i.MaxPlayers,
+		&i.AllowedLanguages,
+		&i.MaxSubmissions,
+		&i.WrongAnswerCooldownSeconds,
+		&i.DrawCount,
+		&i.DrawMinDifficulty,
+		&i.DrawMaxDifficulty,
+		&i.DrawTags,
+		&i.StartsAt,
+	)
+	return i, err
+}
+
 const updateSubmission = `-- name: UpdateSubmission :one
 UPDATE submissions
 SET source_code = $2, language_id = $3, stdin = $4, expected_output = $5, stdout = $6, status_id = $7, created_at = $8, finished_at = $9, time = $10, memory = $11, stderr = $12, token = $13, number_of_runs = $14, cpu_time_limit = $15, cpu_extra_time = $16, wall_time_limit = $17, memory_limit = $18, stack_limit = $19, max_processes_and_or_threads = $20, enable_per_process_and_thread_time_limit = $21, enable_per_process_and_thread_memory_limit = $22, max_file_size = $23, compile_output = $24, exit_code = $25, exit_signal = $26, message = $27, wall_time = $28, compiler_options = $29, command_line_arguments = $30, redirect_stderr_to_stdout = $31, callback_url = $32, additional_files = $33, enable_network = $34, started_at = $35, queued_at = $36, updated_at = $37, queue_host = $38, execution_host = $39
@@ -1266,9 +2505,9 @@ func (q *Queries) UpdateSubmission(ctx context.Context, arg UpdateSubmissionPara
 
 const updateTestCase = `-- name: UpdateTestCase :one
 UPDATE test_cases
-SET input = $2, expected_output = $3, time_constraint = $4, space_constraint = $5
+SET input = $2, expected_output = $3, time_constraint = $4, space_constraint = $5, visibility = $6, subtask_id = $7
 WHERE id = $1
-RETURNING id, question_id, input, expected_output, time_constraint, space_constraint
+RETURNING id, question_id, input, expected_output, time_constraint, space_constraint, visibility, subtask_id
 `
 
 type UpdateTestCaseParams struct {
@@ -1277,6 +2516,8 @@ type UpdateTestCaseParams struct {
 	ExpectedOutput  string
 	TimeConstraint  pgtype.Float8
 	SpaceConstraint pgtype.Int4
+	Visibility      string
+	SubtaskID       pgtype.Int4
 }
 
 func (q *Queries) UpdateTestCase(ctx context.Context, arg UpdateTestCaseParams) (TestCase, error) {
@@ -1286,6 +2527,8 @@ func (q *Queries) UpdateTestCase(ctx context.Context, arg UpdateTestCaseParams)
 		arg.ExpectedOutput,
 		arg.TimeConstraint,
 		arg.SpaceConstraint,
+		arg.Visibility,
+		arg.SubtaskID,
 	)
 	var i TestCase
 	err := row.Scan(
@@ -1295,6 +2538,45 @@ func (q *Queries) UpdateTestCase(ctx context.Context, arg UpdateTestCaseParams)
 		&i.ExpectedOutput,
 		&i.TimeConstraint,
 		&i.SpaceConstraint,
+		&i.Visibility,
+		&i.SubtaskID,
+	)
+	return i, err
+}
+
+const upsertRoomQuestionScore = `-- name: UpsertRoomQuestionScore :one
+INSERT INTO room_question_scores (room_id, player_id, question_id, best_score)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (room_id, player_id, question_id) DO UPDATE
+SET best_score = GREATEST(room_question_scores.best_score, EXCLUDED.best_score),
+    updated_at = CASE WHEN EXCLUDED.best_score > room_question_scores.best_score THEN now() ELSE room_question_scores.updated_at END
+RETURNING room_id, player_id, question_id, best_score, updated_at, wrong_attempts
+`
+
+type UpsertRoomQuestionScoreParams struct {
+	RoomID     int32
+	PlayerID   int32
+	QuestionID int32
+	BestScore  int32
+}
+
+// Room Question Scores
+// Keeps the best score of a player on a question, a worse submission never lowers it
+func (q *Queries) UpsertRoomQuestionScore(ctx context.Context, arg UpsertRoomQuestionScoreParams) (RoomQuestionScore, error) {
+	row := q.db.QueryRow(ctx, upsertRoomQuestionScore,
+		arg.RoomID,
+		arg.PlayerID,
+		arg.QuestionID,
+		arg.BestScore,
+	)
+	var i RoomQuestionScore
+	err := row.Scan(
+		&i.RoomID,
+		&i.PlayerID,
+		&i.QuestionID,
+		&i.BestScore,
+		&i.UpdatedAt,
+		&i.WrongAttempts,
 	)
 	return i, err
 }
@@ -0,0 +1,37 @@
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrNoTransactions = errors.New("the database connection can't start transactions")
+
+// txStarter is a DBTX that can start transactions, like a pgx pool, connection or transaction
+type txStarter interface {
+	Begin(ctx context.Context) (pgx.Tx, error)
+}
+
+// InTx runs fn with queries bound to a single transaction.
+// The transaction is committed when fn returns nil and rolled back otherwise
+func (q *Queries) InTx(ctx context.Context, fn func(*Queries) error) error {
+	db, ok := q.db.(txStarter)
+	if !ok {
+		return ErrNoTransactions
+	}
+
+	tx, err := db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	// a no-op once committed
+	defer tx.Rollback(ctx)
+
+	if err := fn(q.WithTx(tx)); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
@@ -28,24 +28,80 @@ WHERE id = $1;
 
 -- Rooms
 -- name: CreateRoom :one
-INSERT INTO rooms (id, name, description)
-VALUES ($1, $2, $3)
+INSERT INTO rooms (id, name, description, feedback_level, scoring_mode, duration_minutes, freeze_minutes, owner_id, visibility, invite_code, password, max_players)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
 RETURNING *;
 
 -- name: GetRoom :one
 SELECT * FROM rooms
 WHERE id = $1;
 
+-- Locks the room until the end of the transaction, state transitions wait for it
+-- name: GetRoomForUpdate :one
+SELECT * FROM rooms
+WHERE id = $1
+FOR UPDATE;
+
 -- name: ListRooms :many
 SELECT * FROM rooms
 ORDER BY id;
 
+-- name: ListPublicRooms :many
+SELECT * FROM rooms
+WHERE visibility = 'public'
+ORDER BY id;
+
+-- ListActiveRooms returns the rooms that need a manager, archived rooms are only kept for their results
+-- name: ListActiveRooms :many
+SELECT * FROM rooms
+WHERE status <> 'archived'
+ORDER BY id;
+
+-- name: GetRoomByInviteCode :one
+SELECT * FROM rooms
+WHERE invite_code = $1;
+
+-- UpdateRoom saves the name, description and settings of a room
 -- name: UpdateRoom :one
 UPDATE rooms
-SET name = $2, description = $3
+SET name = $2,
+    description = $3,
+    allowed_languages = $4,
+    scoring_mode = $5,
+    duration_minutes = $6,
+    feedback_level = $7,
+    max_submissions = $8,
+    wrong_answer_cooldown_seconds = $9
 WHERE id = $1
 RETURNING *;
 
+-- UpdateRoomDraw saves how the questions of the room are drawn when the match starts
+-- name: UpdateRoomDraw :one
+UPDATE rooms
+SET draw_count = $2, draw_min_difficulty = $3, draw_max_difficulty = $4, draw_tags = $5
+WHERE id = $1
+RETURNING *;
+
+-- UpdateRoomStatus moves a room from one state to the next, no row is returned if the room is not in old_status
+-- name: UpdateRoomStatus :one
+UPDATE rooms
+SET status = sqlc.arg(new_status)
+WHERE id = sqlc.arg(id) AND status = sqlc.arg(old_status)
+RETURNING *;
+
+-- CountdownRoom moves a room out of the lobby, starts_at is kept so a restart resumes the countdown
+-- name: CountdownRoom :one
+UPDATE rooms
+SET status = 'countdown', starts_at = $2
+WHERE id = $1 AND status = 'lobby'
+RETURNING *;
+
+-- name: StartRoom :one
+UPDATE rooms
+SET status = 'running', started_at = $2, ends_at = $3
+WHERE id = $1 AND status = 'countdown'
+RETURNING *;
+
 -- name: DeleteRoom :exec
 DELETE FROM rooms
 WHERE id = $1;
@@ -112,8 +168,8 @@ WHERE id = $1 AND language_id = $2;
 
 -- Test Cases
 -- name: CreateTestCase :one
-INSERT INTO test_cases (question_id, input, expected_output, time_constraint, space_constraint)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO test_cases (question_id, input, expected_output, time_constraint, space_constraint, visibility, subtask_id)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
 RETURNING *;
 
 -- name: GetTestCase :one
@@ -123,11 +179,16 @@ WHERE id = $1;
 -- name: ListTestCasesForQuestion :many
 SELECT * FROM test_cases
 WHERE question_id = $1
+ORDER BY visibility = 'hidden', id;
+
+-- name: ListSampleTestCasesForQuestion :many
+SELECT * FROM test_cases
+WHERE question_id = $1 AND visibility = 'sample'
 ORDER BY id;
 
 -- name: UpdateTestCase :one
 UPDATE test_cases
-SET input = $2, expected_output = $3, time_constraint = $4, space_constraint = $5
+SET input = $2, expected_output = $3, time_constraint = $4, space_constraint = $5, visibility = $6, subtask_id = $7
 WHERE id = $1
 RETURNING *;
 
@@ -136,6 +197,96 @@ DELETE FROM test_cases
 WHERE id = $1;
 
 
+-- Subtasks
+-- name: CreateSubtask :one
+INSERT INTO subtasks (question_id, name, weight)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: ListSubtasksForQuestion :many
+SELECT * FROM subtasks
+WHERE question_id = $1
+ORDER BY id;
+
+-- name: DeleteSubtask :exec
+DELETE FROM subtasks
+WHERE id = $1;
+
+
+-- Room Hosts
+-- name: AddRoomHost :exec
+INSERT INTO room_hosts (room_id, player_id)
+VALUES ($1, $2)
+ON CONFLICT (room_id, player_id) DO NOTHING;
+
+-- name: RemoveRoomHost :exec
+DELETE FROM room_hosts
+WHERE room_id = $1 AND player_id = $2;
+
+-- name: ListRoomHosts :many
+SELECT * FROM room_hosts
+WHERE room_id = $1
+ORDER BY player_id;
+
+-- IsRoomHost reports whether the player owns the room or is one of its co-hosts
+-- name: IsRoomHost :one
+SELECT EXISTS (
+  SELECT 1 FROM rooms r WHERE r.id = $1 AND r.owner_id = $2
+) OR EXISTS (
+  SELECT 1 FROM room_hosts rh WHERE rh.room_id = $1 AND rh.player_id = $2
+) AS is_host;
+
+
+-- Room Questions
+-- AddRoomQuestion adds a question to the set of a room, a NULL points keeps the question's own score
+-- name: AddRoomQuestion :exec
+INSERT INTO room_questions (room_id, question_id, position, points)
+VALUES ($1, $2, $3, $4);
+
+-- name: DeleteRoomQuestions :exec
+DELETE FROM room_questions
+WHERE room_id = $1;
+
+-- name: GetRoomQuestion :one
+SELECT * FROM room_questions
+WHERE room_id = $1 AND question_id = $2;
+
+-- ListRoomQuestions returns the question set of a room in order, questions exist once per language so only one row of each is kept
+-- name: ListRoomQuestions :many
+SELECT rq.question_id, rq.position, COALESCE(rq.points, q.score)::integer AS points, q.title, q.description, q.difficulty
+FROM room_questions rq
+JOIN (
+  SELECT DISTINCT ON (id) id, title, description, score, difficulty
+  FROM questions
+  ORDER BY id, language_id
+) q ON q.id = rq.question_id
+WHERE rq.room_id = $1
+ORDER BY rq.position;
+
+-- ListDrawCandidates returns the questions a room can draw from: in the difficulty range, with one of the tags if any
+-- are given, and never solved before by one of the room's players. A max difficulty of 0 has no upper bound
+-- name: ListDrawCandidates :many
+SELECT DISTINCT ON (q.id) q.id, q.difficulty
+FROM questions q
+WHERE q.difficulty >= sqlc.arg(min_difficulty)
+  AND (sqlc.arg(max_difficulty) = 0 OR q.difficulty <= sqlc.arg(max_difficulty))
+  AND (cardinality(sqlc.arg(tags)::text[]) = 0 OR EXISTS (
+    SELECT 1 FROM question_tags qt
+    WHERE qt.question_id = q.id AND qt.tag = ANY(sqlc.arg(tags)::text[])
+  ))
+  AND NOT EXISTS (
+    SELECT 1 FROM room_solves rs
+    JOIN room_players rp ON rp.player_id = rs.player_id
+    WHERE rp.room_id = sqlc.arg(room_id) AND rp.state IS DISTINCT FROM 'LEFT' AND rs.question_id = q.id
+  )
+ORDER BY q.id;
+
+-- Interactors
+-- name: GetInteractor :one
+SELECT * FROM interactors
+WHERE question_id = $1;
+
+
 -- Room Players
 -- name: CreateRoomPlayer :one
 INSERT INTO room_players (room_id, player_id, score, place)
@@ -174,32 +325,151 @@ RETURNING *;
 DELETE FROM room_players
 WHERE room_id = $1 AND player_id = $2;
 
+-- name: UpdateRoomPlayerState :exec
+UPDATE room_players
+SET state = $3
+WHERE room_id = $1 AND player_id = $2;
+
+-- CountRoomPlayers counts the players taking a seat in the room, kicked players free theirs
+-- name: CountRoomPlayers :one
+SELECT COUNT(*) FROM room_players
+WHERE room_id = $1 AND state IS DISTINCT FROM 'LEFT';
+
+-- DisconnectRoomPlayers marks the players of the room as disconnected, their connections did not survive a restart
+-- name: DisconnectRoomPlayers :exec
+UPDATE room_players
+SET state = 'DISCONNECTED'
+WHERE room_id = $1 AND state = 'PRESENT';
+
+-- CompleteRoomPlayers marks the players still in the room once the match is over
+-- name: CompleteRoomPlayers :exec
+UPDATE room_players
+SET state = 'COMPLETED'
+WHERE room_id = $1 AND state = 'PRESENT';
+
 -- name: GetLeaderboardForRoom :many
-SELECT p.name, rp.score, rp.place
+SELECT rp.player_id, p.name, rp.score, rp.place, COALESCE(pen.penalty, 0)::integer AS penalty
 FROM room_players rp
 JOIN players p ON rp.player_id = p.id
+LEFT JOIN (
+  SELECT player_id, SUM(penalty) AS penalty
+  FROM room_solves
+  WHERE room_id = $1
+  GROUP BY player_id
+) pen ON pen.player_id = rp.player_id
 WHERE rp.room_id = $1
 ORDER BY rp.place;
 
+-- UpdateRoomPlayerRanks ranks by score, ties are broken by the penalty time of the solve ledger,
+-- which is only ever non-zero in ICPC rooms
 -- name: UpdateRoomPlayerRanks :exec
-WITH ranked_players AS (
-  SELECT
-    player_id,
-    RANK() OVER (ORDER BY score DESC) as new_place
-  FROM room_players
+WITH penalties AS (
+  SELECT player_id, SUM(penalty) AS penalty
+  FROM room_solves
   WHERE room_id = $1
+  GROUP BY player_id
+),
+ranked_players AS (
+  SELECT
+    rp.player_id,
+    RANK() OVER (ORDER BY rp.score DESC, COALESCE(pen.penalty, 0)) as new_place
+  FROM room_players rp
+  LEFT JOIN penalties pen ON pen.player_id = rp.player_id
+  WHERE rp.room_id = $1
 )
 UPDATE room_players rp
 SET place = rp_ranked.new_place
 FROM ranked_players rp_ranked
 WHERE rp.room_id = $1 AND rp.player_id = rp_ranked.player_id;
 
+-- Room Question Scores
+-- Keeps the best score of a player on a question, a worse submission never lowers it
+-- name: UpsertRoomQuestionScore :one
+INSERT INTO room_question_scores (room_id, player_id, question_id, best_score)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (room_id, player_id, question_id) DO UPDATE
+SET best_score = GREATEST(room_question_scores.best_score, EXCLUDED.best_score),
+    updated_at = CASE WHEN EXCLUDED.best_score > room_question_scores.best_score THEN now() ELSE room_question_scores.updated_at END
+RETURNING *;
+
+-- name: GetRoomQuestionScore :one
+SELECT * FROM room_question_scores
+WHERE room_id = $1 AND player_id = $2 AND question_id = $3;
+
+-- RecordRoomQuestionWrongAttempt counts a rejected submission, attempts after the question is solved are ignored
+-- name: RecordRoomQuestionWrongAttempt :exec
+INSERT INTO room_question_scores (room_id, player_id, question_id, wrong_attempts)
+VALUES ($1, $2, $3, 1)
+ON CONFLICT (room_id, player_id, question_id) DO UPDATE
+SET wrong_attempts = room_question_scores.wrong_attempts + 1
+WHERE NOT EXISTS (
+  SELECT 1 FROM room_solves rs
+  WHERE rs.room_id = $1 AND rs.player_id = $2 AND rs.question_id = $3
+);
+
+-- name: ListRoomQuestionScores :many
+SELECT * FROM room_question_scores
+WHERE room_id = $1
+ORDER BY player_id, question_id;
+
+-- RefreshRoomPlayerScore sets the room score of a player from the solve ledger,
+-- falling back to the best partial score of the questions they have not solved
+-- name: RefreshRoomPlayerScore :one
+UPDATE room_players
+SET score = (
+  SELECT COALESCE(SUM(COALESCE(rs.awarded_points, rqs.best_score)), 0)::integer
+  FROM room_question_scores rqs
+  LEFT JOIN room_solves rs
+    ON rs.room_id = rqs.room_id AND rs.player_id = rqs.player_id AND rs.question_id = rqs.question_id
+  WHERE rqs.room_id = $1 AND rqs.player_id = $2
+)
+WHERE room_id = $1 AND player_id = $2
+RETURNING *;
+
+-- Room Solves
+-- The solve ledger, a question is only ever awarded once per player and room.
+-- Returns no row if the player already solved the question
+-- name: CreateRoomSolve :one
+INSERT INTO room_solves (room_id, player_id, question_id, submission_id, solved_at, awarded_points, penalty)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (room_id, player_id, question_id) DO NOTHING
+RETURNING *;
+
+-- name: GetRoomSolve :one
+SELECT * FROM room_solves
+WHERE room_id = $1 AND player_id = $2 AND question_id = $3;
+
+-- name: ListRoomSolves :many
+SELECT * FROM room_solves
+WHERE room_id = $1
+ORDER BY solved_at;
+
+-- name: CountRoomQuestionSolvers :one
+SELECT COUNT(*) FROM room_solves
+WHERE room_id = $1 AND question_id = $2;
+
+-- Scoreboard Freezes
+-- The freeze of a room outlives its manager, so a restart or an eviction can't lose the results kept back
+-- name: SaveRoomFreeze :exec
+INSERT INTO room_freezes (room_id, state, lifted)
+VALUES ($1, $2, $3)
+ON CONFLICT (room_id) DO UPDATE SET state = EXCLUDED.state, lifted = EXCLUDED.lifted;
+
+-- name: GetRoomFreeze :one
+SELECT * FROM room_freezes
+WHERE room_id = $1;
+
 -- Submissions
 -- name: CreateSubmission :one
 INSERT INTO submissions (source_code, language_id, stdin, expected_output, stdout, status_id, created_at, finished_at, time, memory, stderr, token, number_of_runs, cpu_time_limit, cpu_extra_time, wall_time_limit, memory_limit, stack_limit, max_processes_and_or_threads, enable_per_process_and_thread_time_limit, enable_per_process_and_thread_memory_limit, max_file_size, compile_output, exit_code, exit_signal, message, wall_time, compiler_options, command_line_arguments, redirect_stderr_to_stdout, callback_url, additional_files, enable_network, started_at, queued_at, updated_at, queue_host, execution_host)
 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32, $33, $34, $35, $36, $37, $38)
 RETURNING *;
 
+-- name: CreateAcceptedSubmission :one
+INSERT INTO submissions (source_code, language_id, message, created_at, finished_at)
+VALUES ($1, $2, $3, $4, now())
+RETURNING id;
+
 -- name: GetSubmission :one
 SELECT * FROM submissions
 WHERE id = $1;
@@ -1,6 +1,14 @@
 -- WARNING: This schema is for context only and is not meant to be run.
 -- Table order and constraints may not be valid for execution.
 
+CREATE TABLE public.interactors (
+  question_id integer NOT NULL,
+  language_id integer NOT NULL,
+  source_code text NOT NULL,
+  time_limit_second double precision,
+  CONSTRAINT interactors_pkey PRIMARY KEY (question_id),
+  CONSTRAINT interactors_language_id_fkey FOREIGN KEY (language_id) REFERENCES public.languages(id)
+);
 CREATE TABLE public.languages (
   id integer NOT NULL DEFAULT nextval('languages_id_seq'::regclass),
   name character varying NOT NULL UNIQUE,
@@ -26,6 +34,25 @@ CREATE TABLE public.questions (
   CONSTRAINT questions_pkey PRIMARY KEY (id, language_id),
   CONSTRAINT questions_language_id_fkey FOREIGN KEY (language_id) REFERENCES public.languages(id)
 );
+CREATE TABLE public.question_tags (
+  question_id integer NOT NULL,
+  tag text NOT NULL,
+  CONSTRAINT question_tags_pkey PRIMARY KEY (question_id, tag)
+);
+CREATE TABLE public.room_freezes (
+  room_id integer NOT NULL,
+  state jsonb NOT NULL,
+  lifted boolean NOT NULL DEFAULT false,
+  CONSTRAINT room_freezes_pkey PRIMARY KEY (room_id),
+  CONSTRAINT room_freezes_room_id_fkey FOREIGN KEY (room_id) REFERENCES public.rooms(id) ON DELETE CASCADE
+);
+CREATE TABLE public.room_hosts (
+  room_id integer NOT NULL,
+  player_id integer NOT NULL,
+  CONSTRAINT room_hosts_pkey PRIMARY KEY (room_id, player_id),
+  CONSTRAINT room_hosts_room_id_fkey FOREIGN KEY (room_id) REFERENCES public.rooms(id) ON DELETE CASCADE,
+  CONSTRAINT room_hosts_player_id_fkey FOREIGN KEY (player_id) REFERENCES public.players(id)
+);
 CREATE TABLE public.room_players (
   room_id integer NOT NULL,
   player_id integer NOT NULL,
@@ -36,11 +63,62 @@ CREATE TABLE public.room_players (
   CONSTRAINT room_players_player_id_fkey FOREIGN KEY (player_id) REFERENCES public.players(id),
   CONSTRAINT room_players_room_id_fkey FOREIGN KEY (room_id) REFERENCES public.rooms(id)
 );
+CREATE TABLE public.room_questions (
+  room_id integer NOT NULL,
+  question_id integer NOT NULL,
+  position integer NOT NULL,
+  points integer CHECK (points >= 0),
+  CONSTRAINT room_questions_pkey PRIMARY KEY (room_id, question_id),
+  CONSTRAINT room_questions_room_id_fkey FOREIGN KEY (room_id) REFERENCES public.rooms(id) ON DELETE CASCADE
+);
+CREATE TABLE public.room_question_scores (
+  room_id integer NOT NULL,
+  player_id integer NOT NULL,
+  question_id integer NOT NULL,
+  best_score integer NOT NULL DEFAULT 0,
+  updated_at timestamp without time zone NOT NULL DEFAULT now(),
+  wrong_attempts integer NOT NULL DEFAULT 0,
+  CONSTRAINT room_question_scores_pkey PRIMARY KEY (room_id, player_id, question_id),
+  CONSTRAINT room_question_scores_room_player_fkey FOREIGN KEY (room_id, player_id) REFERENCES public.room_players(room_id, player_id) ON DELETE CASCADE
+);
+CREATE TABLE public.room_solves (
+  room_id integer NOT NULL,
+  player_id integer NOT NULL,
+  question_id integer NOT NULL,
+  submission_id integer,
+  solved_at timestamp without time zone NOT NULL,
+  awarded_points integer NOT NULL,
+  penalty integer NOT NULL DEFAULT 0,
+  CONSTRAINT room_solves_pkey PRIMARY KEY (room_id, player_id, question_id),
+  CONSTRAINT room_solves_room_player_fkey FOREIGN KEY (room_id, player_id) REFERENCES public.room_players(room_id, player_id) ON DELETE CASCADE,
+  CONSTRAINT room_solves_submission_id_fkey FOREIGN KEY (submission_id) REFERENCES public.submissions(id)
+);
 CREATE TABLE public.rooms (
   id integer NOT NULL DEFAULT nextval('rooms_id_seq'::regclass),
   name character varying NOT NULL,
   description text,
-  CONSTRAINT rooms_pkey PRIMARY KEY (id)
+  feedback_level text NOT NULL DEFAULT 'verdict'::text CHECK (feedback_level = ANY (ARRAY['verdict'::text, 'first_failing'::text, 'sample_diff'::text])),
+  scoring_mode text NOT NULL DEFAULT 'fixed'::text,
+  started_at timestamp without time zone,
+  ends_at timestamp without time zone,
+  freeze_minutes integer NOT NULL DEFAULT 0 CHECK (freeze_minutes >= 0),
+  status text NOT NULL DEFAULT 'lobby'::text CHECK (status = ANY (ARRAY['lobby'::text, 'countdown'::text, 'running'::text, 'finished'::text, 'archived'::text])),
+  duration_minutes integer NOT NULL DEFAULT 0 CHECK (duration_minutes >= 0),
+  owner_id integer,
+  visibility text NOT NULL DEFAULT 'public'::text CHECK (visibility = ANY (ARRAY['public'::text, 'private'::text, 'unlisted'::text])),
+  invite_code text UNIQUE,
+  password text, -- bcrypt hash
+  max_players integer NOT NULL DEFAULT 0 CHECK (max_players >= 0),
+  allowed_languages text[] NOT NULL DEFAULT '{}'::text[],
+  max_submissions integer NOT NULL DEFAULT 0 CHECK (max_submissions >= 0),
+  wrong_answer_cooldown_seconds integer NOT NULL DEFAULT 0 CHECK (wrong_answer_cooldown_seconds >= 0),
+  draw_count integer NOT NULL DEFAULT 0 CHECK (draw_count >= 0),
+  draw_min_difficulty integer NOT NULL DEFAULT 0,
+  draw_max_difficulty integer NOT NULL DEFAULT 0,
+  draw_tags text[] NOT NULL DEFAULT '{}'::text[],
+  starts_at timestamp without time zone,
+  CONSTRAINT rooms_pkey PRIMARY KEY (id),
+  CONSTRAINT rooms_owner_id_fkey FOREIGN KEY (owner_id) REFERENCES public.players(id)
 );
 CREATE TABLE public.submissions (
   id integer NOT NULL DEFAULT nextval('submissions_id_seq'::regclass),
@@ -85,6 +163,13 @@ CREATE TABLE public.submissions (
   CONSTRAINT submissions_pkey PRIMARY KEY (id),
   CONSTRAINT fk_languages FOREIGN KEY (language_id) REFERENCES public.languages(id)
 );
+CREATE TABLE public.subtasks (
+  id integer NOT NULL DEFAULT nextval('subtasks_id_seq'::regclass),
+  question_id integer NOT NULL,
+  name character varying NOT NULL,
+  weight integer NOT NULL CHECK (weight > 0),
+  CONSTRAINT subtasks_pkey PRIMARY KEY (id)
+);
 CREATE TABLE public.test_cases (
   id integer NOT NULL DEFAULT nextval('test_cases_id_seq'::regclass),
   question_id integer NOT NULL,
@@ -92,5 +177,8 @@ CREATE TABLE public.test_cases (
   expected_output text NOT NULL,
   time_constraint double precision,
   space_constraint integer,
-  CONSTRAINT test_cases_pkey PRIMARY KEY (id)
+  visibility text NOT NULL DEFAULT 'hidden'::text CHECK (visibility = ANY (ARRAY['sample'::text, 'hidden'::text])),
+  subtask_id integer,
+  CONSTRAINT test_cases_pkey PRIMARY KEY (id),
+  CONSTRAINT test_cases_subtask_id_fkey FOREIGN KEY (subtask_id) REFERENCES public.subtasks(id) ON DELETE SET NULL
 );